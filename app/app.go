@@ -3,16 +3,39 @@ package app
 import (
 	"context"
 
+	"aigendrug.com/aigendrug-cid-2025-server/app/assignment"
+	"aigendrug.com/aigendrug-cid-2025-server/app/auth"
 	"aigendrug.com/aigendrug-cid-2025-server/app/chat"
+	"aigendrug.com/aigendrug-cid-2025-server/app/evaluation"
+	"aigendrug.com/aigendrug-cid-2025-server/app/events"
+	"aigendrug.com/aigendrug-cid-2025-server/app/export"
+	"aigendrug.com/aigendrug-cid-2025-server/app/feedback"
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/inflight"
+	"aigendrug.com/aigendrug-cid-2025-server/app/presence"
+	"aigendrug.com/aigendrug-cid-2025-server/app/prompt"
 	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/app/sessiontemplate"
+	"aigendrug.com/aigendrug-cid-2025-server/app/summary"
 	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
 	"github.com/gin-gonic/gin"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func SetupRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool) {
-	chat.SetupChatRoutes(c, router, db)
+func SetupRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool, chatHub *hub.Hub, toolHub *hub.Hub, tracker *presence.Tracker, tickets *auth.TicketIssuer, guard *auth.SocketGuard, operations *inflight.Tracker, assignments *assignment.Workflow) {
+	chat.SetupChatRoutes(c, router, db, chatHub, tracker, guard, operations, assignments)
 	session.SetupSessionRoutes(c, router, db)
-	tool.SetupToolRoutes(c, router, db)
+	tool.SetupToolRoutes(c, router, db, toolHub, tracker, guard, operations, assignments)
+	evaluation.SetupEvaluationRoutes(c, router, db)
+	feedback.SetupFeedbackRoutes(c, router, db)
+	prompt.SetupPromptRoutes(c, router, db)
+	events.SetupEventRoutes(c, router, db, chatHub, toolHub, guard)
+	presence.SetupPresenceRoutes(c, router, db)
+	auth.SetupAuthRoutes(c, router, db, tickets)
+	inflight.SetupInflightRoutes(c, router, db)
+	assignment.SetupAssignmentRoutes(c, router, db, assignments)
+	export.SetupExportRoutes(c, router, db)
+	sessiontemplate.SetupSessionTemplateRoutes(c, router, db)
+	summary.SetupSummaryRoutes(c, router, db, chatHub)
 }
@@ -0,0 +1,106 @@
+package assignment
+
+import (
+	"errors"
+	"net/http"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"github.com/gin-gonic/gin"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type AssignmentController struct {
+	assignmentService AssignmentService
+	workflow          *Workflow
+}
+
+func NewAssignmentController(assignmentService AssignmentService, workflow *Workflow) *AssignmentController {
+	return &AssignmentController{assignmentService: assignmentService, workflow: workflow}
+}
+
+func (ac *AssignmentController) GetAssignment(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	assignment, err := ac.assignmentService.ReadAssignment(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(assignmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, assignment)
+}
+
+func (ac *AssignmentController) ConfirmTool(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	assignment, err := ac.workflow.Confirm(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(assignmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, assignment)
+}
+
+func (ac *AssignmentController) OverrideTool(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto OverrideToolDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	assignment, err := ac.workflow.Override(c.Request.Context(), sessionID, &dto)
+	if err != nil {
+		c.JSON(assignmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, assignment)
+}
+
+func (ac *AssignmentController) UpdateToolStatus(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto UpdateToolStatusDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	assignment, err := ac.workflow.SetStatus(c.Request.Context(), sessionID, &dto)
+	if err != nil {
+		c.JSON(assignmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, assignment)
+}
+
+func assignmentErrorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound), errors.Is(err, ErrToolNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNoSuggestion), errors.Is(err, ErrToolRunning), errors.Is(err, ErrInvalidToolTransition), errors.Is(err, session.ErrSessionReadOnly):
+		return http.StatusConflict
+	case errors.As(err, &validationErrors):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,44 @@
+package assignment
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ToolStatusSuggested     = "suggested"
+	ToolStatusConfirmed     = "confirmed"
+	ToolStatusAwaitingInput = "awaiting-input"
+	ToolStatusRunning       = "running"
+	ToolStatusCompleted     = "completed"
+	ToolStatusFailed        = "failed"
+)
+
+// toolStatusTransitions lists the tool statuses each tool status may change to once a tool is confirmed.
+// Suggestions and overrides replace the assignment instead, from any status but running.
+var toolStatusTransitions = map[string][]string{
+	ToolStatusConfirmed:     {ToolStatusAwaitingInput, ToolStatusRunning},
+	ToolStatusAwaitingInput: {ToolStatusRunning},
+	ToolStatusRunning:       {ToolStatusCompleted, ToolStatusFailed},
+	ToolStatusCompleted:     {ToolStatusAwaitingInput, ToolStatusRunning},
+	ToolStatusFailed:        {ToolStatusAwaitingInput, ToolStatusRunning},
+}
+
+// ToolAssignment is the tool assigned to a session and how far its workflow got.
+// It is also the payload of "tool-status" envelopes.
+type ToolAssignment struct {
+	SessionID       uuid.UUID  `json:"session_id"`
+	AssignedToolID  *uuid.UUID `json:"assigned_tool_id"`
+	ToolStatus      *string    `json:"tool_status"`
+	ToolSelectionID *uuid.UUID `json:"tool_selection_id"`
+	ChangedAt       *time.Time `json:"changed_at"`
+}
+
+type OverrideToolDTO struct {
+	ToolID uuid.UUID `json:"tool_id" validate:"required"`
+}
+
+type UpdateToolStatusDTO struct {
+	Status string `json:"status" validate:"required,oneof=awaiting-input running completed failed"`
+}
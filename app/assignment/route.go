@@ -0,0 +1,21 @@
+package assignment
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupAssignmentRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool, workflow *Workflow) {
+	assignmentService := NewAssignmentService(c, db)
+	assignmentController := NewAssignmentController(assignmentService, workflow)
+
+	assignmentRoutes := router.Group("/v1/assignment")
+	{
+		assignmentRoutes.GET("/:sessionID", assignmentController.GetAssignment)
+		assignmentRoutes.POST("/:sessionID/confirm", assignmentController.ConfirmTool)
+		assignmentRoutes.POST("/:sessionID/override", assignmentController.OverrideTool)
+		assignmentRoutes.PATCH("/:sessionID/status", assignmentController.UpdateToolStatus)
+	}
+}
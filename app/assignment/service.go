@@ -0,0 +1,213 @@
+package assignment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/evaluation"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/database"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrNoSuggestion = errors.New("no tool suggested for the session")
+var ErrToolRunning = errors.New("assigned tool is running")
+var ErrInvalidToolTransition = errors.New("invalid tool status transition")
+var ErrToolNotFound = errors.New("tool not found")
+
+const assignmentColumns = `id, assigned_tool_id, tool_status, tool_selection_id, tool_status_changed_at`
+
+type AssignmentService interface {
+	ReadAssignment(rctx context.Context, sessionID uuid.UUID) (*ToolAssignment, error)
+	SuggestTool(rctx context.Context, sessionID uuid.UUID, toolID uuid.UUID, selectionID *uuid.UUID) (*ToolAssignment, bool, error)
+	ConfirmTool(rctx context.Context, sessionID uuid.UUID) (*ToolAssignment, error)
+	OverrideTool(rctx context.Context, sessionID uuid.UUID, dto *OverrideToolDTO) (*ToolAssignment, error)
+	UpdateToolStatus(rctx context.Context, sessionID uuid.UUID, dto *UpdateToolStatusDTO) (*ToolAssignment, error)
+}
+
+type assignmentService struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func NewAssignmentService(c context.Context, db *pgxpool.Pool) AssignmentService {
+	return &assignmentService{ctx: c, db: db}
+}
+
+// CanTransition reports whether the tool status of a session may change from one status to another.
+func CanTransition(from *string, to string) bool {
+	current := ""
+	if from != nil {
+		current = *from
+	}
+	return slices.Contains(toolStatusTransitions[current], to)
+}
+
+func (s *assignmentService) ReadAssignment(rctx context.Context, sessionID uuid.UUID) (*ToolAssignment, error) {
+	assignment, err := scanAssignment(s.db.QueryRow(rctx, "SELECT "+assignmentColumns+" FROM sessions WHERE id = $1 AND deleted_at IS NULL", sessionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, session.ErrSessionNotFound
+	}
+	return assignment, err
+}
+
+// SuggestTool assigns the tool selected in chat, pending confirmation by the user. It reports false and keeps
+// the assignment when the same tool was already confirmed, and fails with ErrToolRunning while a tool runs.
+func (s *assignmentService) SuggestTool(rctx context.Context, sessionID uuid.UUID, toolID uuid.UUID, selectionID *uuid.UUID) (*ToolAssignment, bool, error) {
+	var changed bool
+	assignment, err := database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*ToolAssignment, error) {
+		current, err := lockAssignment(rctx, tx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if current.isStatus(ToolStatusRunning) {
+			return nil, ErrToolRunning
+		}
+		if current.AssignedToolID != nil && *current.AssignedToolID == toolID && !current.isStatus(ToolStatusSuggested) {
+			return current, nil
+		}
+
+		changed = true
+		return updateAssignment(rctx, tx, sessionID, &toolID, ToolStatusSuggested, selectionID)
+	})
+	return assignment, changed, err
+}
+
+// ConfirmTool accepts the suggested tool and records the outcome of its selection for evaluation.
+func (s *assignmentService) ConfirmTool(rctx context.Context, sessionID uuid.UUID) (*ToolAssignment, error) {
+	return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*ToolAssignment, error) {
+		current, err := lockAssignment(rctx, tx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if !current.isStatus(ToolStatusSuggested) {
+			return nil, ErrNoSuggestion
+		}
+
+		if err := resolveSelection(rctx, tx, current.ToolSelectionID, evaluation.SelectionOutcomeAccepted, nil); err != nil {
+			return nil, err
+		}
+		return updateAssignment(rctx, tx, sessionID, current.AssignedToolID, ToolStatusConfirmed, current.ToolSelectionID)
+	})
+}
+
+// OverrideTool assigns a tool of the user's choice, confirmed right away. If it replaces a suggestion,
+// the selection is recorded as overridden, or as accepted when the user picked the suggested tool anyway.
+func (s *assignmentService) OverrideTool(rctx context.Context, sessionID uuid.UUID, dto *OverrideToolDTO) (*ToolAssignment, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("tool assignment validation failed: %w", err)
+	}
+
+	return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*ToolAssignment, error) {
+		current, err := lockAssignment(rctx, tx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if current.isStatus(ToolStatusRunning) {
+			return nil, ErrToolRunning
+		}
+
+		var exists bool
+		if err := tx.QueryRow(rctx, "SELECT EXISTS (SELECT 1 FROM tools WHERE id = $1)", dto.ToolID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrToolNotFound
+		}
+
+		if current.isStatus(ToolStatusSuggested) {
+			outcome, overrideToolID := evaluation.SelectionOutcomeOverridden, &dto.ToolID
+			if current.AssignedToolID != nil && *current.AssignedToolID == dto.ToolID {
+				outcome, overrideToolID = evaluation.SelectionOutcomeAccepted, nil
+			}
+			if err := resolveSelection(rctx, tx, current.ToolSelectionID, outcome, overrideToolID); err != nil {
+				return nil, err
+			}
+		}
+		return updateAssignment(rctx, tx, sessionID, &dto.ToolID, ToolStatusConfirmed, current.ToolSelectionID)
+	})
+}
+
+// UpdateToolStatus moves a confirmed tool through input, running and its result.
+func (s *assignmentService) UpdateToolStatus(rctx context.Context, sessionID uuid.UUID, dto *UpdateToolStatusDTO) (*ToolAssignment, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("tool assignment validation failed: %w", err)
+	}
+
+	return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*ToolAssignment, error) {
+		current, err := lockAssignment(rctx, tx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if current.isStatus(dto.Status) {
+			return current, nil
+		}
+		if !CanTransition(current.ToolStatus, dto.Status) {
+			from := ""
+			if current.ToolStatus != nil {
+				from = *current.ToolStatus
+			}
+			return nil, fmt.Errorf("%w: %q to %q", ErrInvalidToolTransition, from, dto.Status)
+		}
+		return updateAssignment(rctx, tx, sessionID, current.AssignedToolID, dto.Status, current.ToolSelectionID)
+	})
+}
+
+func (a *ToolAssignment) isStatus(status string) bool {
+	return a.ToolStatus != nil && *a.ToolStatus == status
+}
+
+// lockAssignment locks the session for the rest of tx. Tools can only be assigned and run in sessions
+// that accept messages.
+func lockAssignment(rctx context.Context, tx database.DbExecutor, sessionID uuid.UUID) (*ToolAssignment, error) {
+	if _, err := session.LockWritableSession(rctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	return scanAssignment(tx.QueryRow(rctx, "SELECT "+assignmentColumns+" FROM sessions WHERE id = $1", sessionID))
+}
+
+func updateAssignment(rctx context.Context, tx database.DbExecutor, sessionID uuid.UUID, toolID *uuid.UUID, status string, selectionID *uuid.UUID) (*ToolAssignment, error) {
+	now := time.Now()
+	return scanAssignment(tx.QueryRow(rctx, `
+        UPDATE sessions
+        SET assigned_tool_id = $2, tool_status = $3, tool_selection_id = $4, tool_status_changed_at = $5, updated_at = $5
+        WHERE id = $1
+        RETURNING `+assignmentColumns, sessionID, toolID, status, selectionID, now))
+}
+
+// resolveSelection records the user's response to a logged selection. Selections that were already
+// resolved through the evaluation API keep their outcome.
+func resolveSelection(rctx context.Context, tx database.DbExecutor, selectionID *uuid.UUID, outcome string, overrideToolID *uuid.UUID) error {
+	if selectionID == nil {
+		return nil
+	}
+
+	err := evaluation.ResolveSelection(rctx, tx, *selectionID, outcome, overrideToolID)
+	if errors.Is(err, evaluation.ErrSelectionResolved) || errors.Is(err, evaluation.ErrSelectionNotFound) {
+		return nil
+	}
+	return err
+}
+
+func scanAssignment(row pgx.Row) (*ToolAssignment, error) {
+	var assignment ToolAssignment
+	err := row.Scan(
+		&assignment.SessionID,
+		&assignment.AssignedToolID,
+		&assignment.ToolStatus,
+		&assignment.ToolSelectionID,
+		&assignment.ChangedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
@@ -0,0 +1,38 @@
+package assignment
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	status := func(s string) *string { return &s }
+
+	tests := []struct {
+		name string
+		from *string
+		to   string
+		want bool
+	}{
+		{name: "unassigned to running", from: nil, to: ToolStatusRunning, want: false},
+		{name: "unassigned to confirmed", from: nil, to: ToolStatusConfirmed, want: false},
+		{name: "suggested to running", from: status(ToolStatusSuggested), to: ToolStatusRunning, want: false},
+		{name: "confirmed to awaiting input", from: status(ToolStatusConfirmed), to: ToolStatusAwaitingInput, want: true},
+		{name: "confirmed to running", from: status(ToolStatusConfirmed), to: ToolStatusRunning, want: true},
+		{name: "confirmed to completed", from: status(ToolStatusConfirmed), to: ToolStatusCompleted, want: false},
+		{name: "awaiting input to running", from: status(ToolStatusAwaitingInput), to: ToolStatusRunning, want: true},
+		{name: "awaiting input to completed", from: status(ToolStatusAwaitingInput), to: ToolStatusCompleted, want: false},
+		{name: "running to completed", from: status(ToolStatusRunning), to: ToolStatusCompleted, want: true},
+		{name: "running to failed", from: status(ToolStatusRunning), to: ToolStatusFailed, want: true},
+		{name: "running to awaiting input", from: status(ToolStatusRunning), to: ToolStatusAwaitingInput, want: false},
+		{name: "completed to running", from: status(ToolStatusCompleted), to: ToolStatusRunning, want: true},
+		{name: "completed to awaiting input", from: status(ToolStatusCompleted), to: ToolStatusAwaitingInput, want: true},
+		{name: "failed to running", from: status(ToolStatusFailed), to: ToolStatusRunning, want: true},
+		{name: "failed to completed", from: status(ToolStatusFailed), to: ToolStatusCompleted, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%v, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package assignment
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+Workflow changes the tool assigned to a session and announces every change as a "tool-status" envelope
+on the chat hub, next to the presence of the session.
+
+The chat suggests the tool it selected for each user message. The user confirms the suggestion or
+overrides it with another tool, and tool runs move the confirmed tool to running and then to completed
+or failed. Suggestions and runs are best effort: failures are logged and never break the answer.
+*/
+type Workflow struct {
+	db  *pgxpool.Pool
+	hub *hub.Hub
+}
+
+func NewWorkflow(db *pgxpool.Pool, h *hub.Hub) *Workflow {
+	return &Workflow{db: db, hub: h}
+}
+
+// Suggest assigns the tool selected in chat unless a tool is running in the session.
+func (w *Workflow) Suggest(sessionID uuid.UUID, toolID uuid.UUID, selectionID *uuid.UUID) {
+	assignment, changed, err := NewAssignmentService(context.Background(), w.db).SuggestTool(context.Background(), sessionID, toolID, selectionID)
+	if errors.Is(err, ErrToolRunning) || errors.Is(err, session.ErrSessionReadOnly) {
+		return
+	}
+	if err != nil {
+		log.Println("Tool Assignment Error:", err)
+		return
+	}
+	if changed {
+		w.announce(assignment)
+	}
+}
+
+func (w *Workflow) Confirm(rctx context.Context, sessionID uuid.UUID) (*ToolAssignment, error) {
+	assignment, err := NewAssignmentService(rctx, w.db).ConfirmTool(rctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	w.announce(assignment)
+	return assignment, nil
+}
+
+func (w *Workflow) Override(rctx context.Context, sessionID uuid.UUID, dto *OverrideToolDTO) (*ToolAssignment, error) {
+	assignment, err := NewAssignmentService(rctx, w.db).OverrideTool(rctx, sessionID, dto)
+	if err != nil {
+		return nil, err
+	}
+	w.announce(assignment)
+	return assignment, nil
+}
+
+func (w *Workflow) SetStatus(rctx context.Context, sessionID uuid.UUID, dto *UpdateToolStatusDTO) (*ToolAssignment, error) {
+	assignment, err := NewAssignmentService(rctx, w.db).UpdateToolStatus(rctx, sessionID, dto)
+	if err != nil {
+		return nil, err
+	}
+	w.announce(assignment)
+	return assignment, nil
+}
+
+// RunStarted marks the assigned tool as running when a run of it starts. Runs of other tools,
+// or of a tool that was only suggested, leave the assignment alone.
+func (w *Workflow) RunStarted(sessionID uuid.UUID, toolID uuid.UUID) {
+	w.setRunStatus(sessionID, toolID, ToolStatusRunning)
+}
+
+// RunFinished records the result of a run reported by RunStarted.
+func (w *Workflow) RunFinished(sessionID uuid.UUID, toolID uuid.UUID, failed bool) {
+	status := ToolStatusCompleted
+	if failed {
+		status = ToolStatusFailed
+	}
+	w.setRunStatus(sessionID, toolID, status)
+}
+
+func (w *Workflow) setRunStatus(sessionID uuid.UUID, toolID uuid.UUID, status string) {
+	ctx := context.Background()
+	assignmentService := NewAssignmentService(ctx, w.db)
+
+	current, err := assignmentService.ReadAssignment(ctx, sessionID)
+	if err != nil {
+		log.Println("Tool Assignment Error:", err)
+		return
+	}
+	if current.AssignedToolID == nil || *current.AssignedToolID != toolID || !CanTransition(current.ToolStatus, status) {
+		return
+	}
+
+	assignment, err := assignmentService.UpdateToolStatus(ctx, sessionID, &UpdateToolStatusDTO{Status: status})
+	if errors.Is(err, ErrInvalidToolTransition) || errors.Is(err, session.ErrSessionReadOnly) {
+		return
+	}
+	if err != nil {
+		log.Println("Tool Assignment Error:", err)
+		return
+	}
+	w.announce(assignment)
+}
+
+func (w *Workflow) announce(assignment *ToolAssignment) {
+	envelope, err := hub.NewEnvelope(hub.EventToolStatus, "", assignment)
+	if err == nil {
+		err = w.hub.Broadcast(assignment.SessionID.String(), envelope)
+	}
+	if err != nil {
+		log.Println("Broadcast Error:", err)
+	}
+}
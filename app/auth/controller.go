@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"github.com/gin-gonic/gin"
+)
+
+type AuthController struct {
+	sessionService session.SessionService
+	tickets        *TicketIssuer
+}
+
+func NewAuthController(sessionService session.SessionService, tickets *TicketIssuer) *AuthController {
+	return &AuthController{sessionService: sessionService, tickets: tickets}
+}
+
+// CreateTicket issues a ticket for connecting to the sockets and event stream of a session
+func (ac *AuthController) CreateTicket(c *gin.Context) {
+	var dto CreateTicketDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := ac.sessionService.ReadSession(c.Request.Context(), dto.SessionID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, session.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ac.tickets.Issue(dto.SessionID))
+}
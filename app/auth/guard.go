@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// TicketSubprotocol prefixes a ticket passed as WebSocket subprotocol, e.g. new WebSocket(url, ["ticket.<ticket>"]).
+// The server selects that subprotocol in its handshake response, as browsers require.
+const TicketSubprotocol = "ticket."
+
+// SocketGuard admits WebSocket upgrades and event streams from allowed origins with a valid ticket for the session.
+// The ticket is read from the "ticket" query parameter or from a TicketSubprotocol.
+type SocketGuard struct {
+	tickets  *TicketIssuer
+	upgrader websocket.Upgrader
+}
+
+func NewSocketGuard(origins *OriginPolicy, tickets *TicketIssuer) *SocketGuard {
+	return &SocketGuard{
+		tickets:  tickets,
+		upgrader: websocket.Upgrader{CheckOrigin: origins.CheckOrigin},
+	}
+}
+
+// Authorize checks the request's ticket for sessionID and answers 401 if it is missing or invalid.
+// It returns the subprotocol the ticket was passed in, if any.
+func (g *SocketGuard) Authorize(c *gin.Context, sessionID uuid.UUID) (string, bool) {
+	ticket, subprotocol := c.Query("ticket"), ""
+	if ticket == "" {
+		for _, protocol := range websocket.Subprotocols(c.Request) {
+			if strings.HasPrefix(protocol, TicketSubprotocol) {
+				ticket, subprotocol = strings.TrimPrefix(protocol, TicketSubprotocol), protocol
+				break
+			}
+		}
+	}
+	if ticket == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "ticket is required"})
+		return "", false
+	}
+
+	if err := g.tickets.Verify(ticket, sessionID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return subprotocol, true
+}
+
+// Upgrade authorizes the request and upgrades it to a WebSocket connection.
+// It returns nil if the request was refused, in which case the response has already been written.
+func (g *SocketGuard) Upgrade(c *gin.Context, sessionID uuid.UUID) *websocket.Conn {
+	subprotocol, ok := g.Authorize(c, sessionID)
+	if !ok {
+		return nil
+	}
+
+	var responseHeader http.Header
+	if subprotocol != "" {
+		responseHeader = http.Header{"Sec-WebSocket-Protocol": {subprotocol}}
+	}
+
+	conn, err := g.upgrader.Upgrade(c.Writer, c.Request, responseHeader)
+	if err != nil {
+		log.Println("WebSocket Upgrade Error:", err)
+		return nil
+	}
+	return conn
+}
+
+// RequireAPIToken guards an endpoint with "Authorization: Bearer <token>". Without a configured token
+// every request is refused. The token is shared with trusted backends only, never with browsers.
+func RequireAPIToken(token string) gin.HandlerFunc {
+	if token == "" {
+		log.Println("API_TOKEN is not set, every request to token protected endpoints will be refused")
+	}
+
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "API token is not configured"})
+			return
+		}
+
+		bearer, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API token"})
+			return
+		}
+		c.Next()
+	}
+}
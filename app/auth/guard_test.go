@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireAPIToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{name: "matching token", token: "secret", authorization: "Bearer secret", want: http.StatusOK},
+		{name: "wrong token", token: "secret", authorization: "Bearer other", want: http.StatusUnauthorized},
+		{name: "token prefix", token: "secret", authorization: "Bearer secre", want: http.StatusUnauthorized},
+		{name: "missing header", token: "secret", want: http.StatusUnauthorized},
+		{name: "not a bearer token", token: "secret", authorization: "secret", want: http.StatusUnauthorized},
+		{name: "no token configured", token: "", authorization: "Bearer ", want: http.StatusServiceUnavailable},
+		{name: "no token configured without header", token: "", want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/ws-ticket", RequireAPIToken(tt.token), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			request := httptest.NewRequest(http.MethodPost, "/ws-ticket", nil)
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLogFormatter formats access log lines like gin's default logger, with the "ticket" query parameter
+// redacted. Tickets are bearer credentials for a session until they expire, so they must not end up in logs.
+func AccessLogFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		redactTicket(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactTicket replaces the value of the "ticket" query parameter of path. A query that can't be parsed is
+// dropped entirely rather than logged as is.
+func redactTicket(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base + "?[unparsable query]"
+	}
+	if !query.Has("ticket") {
+		return path
+	}
+	query.Set("ticket", "REDACTED")
+	return base + "?" + query.Encode()
+}
@@ -0,0 +1,26 @@
+package auth
+
+import "testing"
+
+func TestRedactTicket(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "no query", path: "/v1/events/abc", want: "/v1/events/abc"},
+		{name: "query without ticket", path: "/v1/chat/message/abc?before=xyz", want: "/v1/chat/message/abc?before=xyz"},
+		{name: "only ticket", path: "/v1/chat/session/ws?ticket=secret", want: "/v1/chat/session/ws?ticket=REDACTED"},
+		{name: "ticket among other parameters", path: "/v1/chat/session/ws?sessionID=abc&ticket=secret&last_seq=4", want: "/v1/chat/session/ws?last_seq=4&sessionID=abc&ticket=REDACTED"},
+		{name: "repeated ticket", path: "/v1/events/abc?ticket=one&ticket=two", want: "/v1/events/abc?ticket=REDACTED"},
+		{name: "unparsable query", path: "/v1/events/abc?ticket=%zz", want: "/v1/events/abc?[unparsable query]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactTicket(tt.path); got != tt.want {
+				t.Errorf("redactTicket(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Ticket struct {
+	Ticket    string    `json:"ticket"`
+	SessionID uuid.UUID `json:"session_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type CreateTicketDTO struct {
+	SessionID uuid.UUID `json:"session_id" binding:"required"`
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var defaultAllowedOrigins = []string{
+	"https://aigendrug-cid-2025.luidium.com",
+	"http://localhost:3000",
+}
+
+// OriginPolicy is the single list of browser origins allowed to call the API, used for CORS
+// and for WebSocket upgrades alike.
+type OriginPolicy struct {
+	origins  []string
+	allowAny bool
+}
+
+var ErrMixedOrigins = errors.New(`"*" cannot be combined with explicit origins`)
+var ErrInvalidOrigin = errors.New("origins must start with http:// or https://")
+
+// NewOriginPolicy allows the given origins, or every origin for "*" alone. A list without any origin
+// falls back to the production and local development clients.
+func NewOriginPolicy(origins []string) (*OriginPolicy, error) {
+	policy := &OriginPolicy{}
+	for _, origin := range origins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		switch origin {
+		case "":
+		case "*":
+			policy.allowAny = true
+		default:
+			if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidOrigin, origin)
+			}
+			policy.origins = append(policy.origins, origin)
+		}
+	}
+
+	if policy.allowAny && len(policy.origins) > 0 {
+		return nil, ErrMixedOrigins
+	}
+	if !policy.allowAny && len(policy.origins) == 0 {
+		policy.origins = defaultAllowedOrigins
+	}
+	return policy, nil
+}
+
+// OriginPolicyFromEnv reads the comma separated ALLOWED_ORIGINS, falling back to the production and local
+// development clients. "*" allows every origin and cannot be combined with explicit origins.
+func OriginPolicyFromEnv() (*OriginPolicy, error) {
+	return NewOriginPolicy(strings.Split(os.Getenv("ALLOWED_ORIGINS"), ","))
+}
+
+func (p *OriginPolicy) Origins() []string {
+	return p.origins
+}
+
+func (p *OriginPolicy) AllowAny() bool {
+	return p.allowAny
+}
+
+func (p *OriginPolicy) Allowed(origin string) bool {
+	if p.allowAny {
+		return true
+	}
+	for _, allowed := range p.origins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOrigin is a websocket.Upgrader CheckOrigin. Requests without an Origin header do not come from
+// a browser page and are left to the ticket check.
+func (p *OriginPolicy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || p.Allowed(origin)
+}
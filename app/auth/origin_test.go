@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestNewOriginPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		origins      []string
+		wantOrigins  []string
+		wantAllowAny bool
+		wantErr      error
+	}{
+		{name: "explicit origins", origins: []string{"https://a.example", " http://localhost:3000/ "}, wantOrigins: []string{"https://a.example", "http://localhost:3000"}},
+		{name: "any origin", origins: []string{"*"}, wantAllowAny: true},
+		{name: "any origin with blanks", origins: []string{"", " * "}, wantAllowAny: true},
+		{name: "empty list falls back", origins: nil, wantOrigins: defaultAllowedOrigins},
+		{name: "blank entries fall back", origins: []string{"", " ", ""}, wantOrigins: defaultAllowedOrigins},
+		{name: "any mixed with explicit", origins: []string{"*", "https://a.example"}, wantErr: ErrMixedOrigins},
+		{name: "origin without scheme", origins: []string{"a.example"}, wantErr: ErrInvalidOrigin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewOriginPolicy(tt.origins)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("NewOriginPolicy() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewOriginPolicy() error = %v", err)
+			}
+			if !slices.Equal(policy.Origins(), tt.wantOrigins) {
+				t.Errorf("Origins() = %v, want %v", policy.Origins(), tt.wantOrigins)
+			}
+			if policy.AllowAny() != tt.wantAllowAny {
+				t.Errorf("AllowAny() = %v, want %v", policy.AllowAny(), tt.wantAllowAny)
+			}
+			// cors panics when both or neither are set
+			if policy.AllowAny() == (len(policy.Origins()) > 0) {
+				t.Errorf("policy sets AllowAny() = %v with %d origins", policy.AllowAny(), len(policy.Origins()))
+			}
+		})
+	}
+}
+
+func TestOriginPolicyCheckOrigin(t *testing.T) {
+	policy, err := NewOriginPolicy([]string{"https://a.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{name: "allowed origin", origin: "https://a.example", want: true},
+		{name: "case insensitive", origin: "HTTPS://A.EXAMPLE", want: true},
+		{name: "other origin", origin: "https://b.example", want: false},
+		{name: "no origin header", origin: "", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/v1/chat/session/ws", nil)
+			if tt.origin != "" {
+				request.Header.Set("Origin", tt.origin)
+			}
+			if got := policy.CheckOrigin(request); got != tt.want {
+				t.Errorf("CheckOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
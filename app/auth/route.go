@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"context"
+	"os"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupAuthRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool, tickets *TicketIssuer) {
+	authController := NewAuthController(session.NewSessionService(c, db), tickets)
+
+	authRoutes := router.Group("/v1/auth", RequireAPIToken(os.Getenv("API_TOKEN")))
+	{
+		authRoutes.POST("/ws-ticket", authController.CreateTicket)
+	}
+}
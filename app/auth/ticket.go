@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidTicket = errors.New("invalid ticket")
+var ErrTicketExpired = errors.New("ticket expired")
+
+const defaultTicketTTL = time.Minute
+
+/*
+Tickets authenticate WebSocket upgrades and event streams, which browsers cannot send an Authorization header with.
+A ticket is "<payload>.<signature>", both base64url encoded, where the payload is "<session id>|<expiry unix>|<nonce>"
+and the signature its HMAC-SHA256 under the shared secret. Tickets are bound to one session and valid until they
+expire, on every instance sharing the secret.
+*/
+type TicketIssuer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewTicketIssuer(secret []byte, ttl time.Duration) *TicketIssuer {
+	return &TicketIssuer{secret: secret, ttl: ttl}
+}
+
+// TicketIssuerFromEnv reads WS_TICKET_SECRET and WS_TICKET_TTL. Without a secret a random one is generated,
+// so tickets are only accepted by the instance that issued them.
+func TicketIssuerFromEnv() *TicketIssuer {
+	secret := []byte(os.Getenv("WS_TICKET_SECRET"))
+	if len(secret) == 0 {
+		log.Println("WS_TICKET_SECRET is not set, tickets will only be valid on this instance")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("Failed to generate ticket secret: %v", err))
+		}
+	}
+
+	ttl, err := time.ParseDuration(os.Getenv("WS_TICKET_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = defaultTicketTTL
+	}
+	return NewTicketIssuer(secret, ttl)
+}
+
+func (t *TicketIssuer) Issue(sessionID uuid.UUID) *Ticket {
+	expiresAt := time.Now().Add(t.ttl)
+	payload := fmt.Sprintf("%s|%d|%s", sessionID, expiresAt.Unix(), uuid.NewString())
+
+	encoding := base64.RawURLEncoding
+	return &Ticket{
+		Ticket:    encoding.EncodeToString([]byte(payload)) + "." + encoding.EncodeToString(t.sign(payload)),
+		SessionID: sessionID,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// Verify checks that ticket was issued for sessionID and has not expired.
+func (t *TicketIssuer) Verify(ticket string, sessionID uuid.UUID) error {
+	encoding := base64.RawURLEncoding
+	encodedPayload, encodedSignature, found := strings.Cut(ticket, ".")
+	if !found {
+		return ErrInvalidTicket
+	}
+	payload, err := encoding.DecodeString(encodedPayload)
+	if err != nil {
+		return ErrInvalidTicket
+	}
+	signature, err := encoding.DecodeString(encodedSignature)
+	if err != nil {
+		return ErrInvalidTicket
+	}
+	if !hmac.Equal(signature, t.sign(string(payload))) {
+		return ErrInvalidTicket
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 3 || fields[0] != sessionID.String() {
+		return ErrInvalidTicket
+	}
+	expiresAt, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return ErrInvalidTicket
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrTicketExpired
+	}
+	return nil
+}
+
+func (t *TicketIssuer) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTicketVerify(t *testing.T) {
+	issuer := NewTicketIssuer([]byte("secret"), time.Minute)
+	sessionID := uuid.New()
+	ticket := issuer.Issue(sessionID).Ticket
+
+	otherSessionTicket := issuer.Issue(uuid.New()).Ticket
+	otherPayload, _, _ := strings.Cut(otherSessionTicket, ".")
+	_, signature, _ := strings.Cut(ticket, ".")
+
+	tests := []struct {
+		name      string
+		issuer    *TicketIssuer
+		ticket    string
+		sessionID uuid.UUID
+		wantErr   error
+	}{
+		{name: "valid ticket", issuer: issuer, ticket: ticket, sessionID: sessionID},
+		{name: "other session", issuer: issuer, ticket: ticket, sessionID: uuid.New(), wantErr: ErrInvalidTicket},
+		{name: "other secret", issuer: NewTicketIssuer([]byte("other"), time.Minute), ticket: ticket, sessionID: sessionID, wantErr: ErrInvalidTicket},
+		{name: "swapped payload", issuer: issuer, ticket: otherPayload + "." + signature, sessionID: sessionID, wantErr: ErrInvalidTicket},
+		{name: "expired", issuer: issuer, ticket: NewTicketIssuer([]byte("secret"), -2*time.Second).Issue(sessionID).Ticket, sessionID: sessionID, wantErr: ErrTicketExpired},
+		{name: "empty", issuer: issuer, ticket: "", sessionID: sessionID, wantErr: ErrInvalidTicket},
+		{name: "no signature", issuer: issuer, ticket: strings.Split(ticket, ".")[0], sessionID: sessionID, wantErr: ErrInvalidTicket},
+		{name: "not base64", issuer: issuer, ticket: "!!!.???", sessionID: sessionID, wantErr: ErrInvalidTicket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.issuer.Verify(tt.ticket, tt.sessionID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTicketIssue(t *testing.T) {
+	issuer := NewTicketIssuer([]byte("secret"), time.Minute)
+	sessionID := uuid.New()
+
+	first, second := issuer.Issue(sessionID), issuer.Issue(sessionID)
+	if first.Ticket == second.Ticket {
+		t.Error("Issue() returned the same ticket twice")
+	}
+	if first.SessionID != sessionID {
+		t.Errorf("SessionID = %v, want %v", first.SessionID, sessionID)
+	}
+	if until := time.Until(first.ExpiresAt); until <= 0 || until > time.Minute {
+		t.Errorf("ExpiresAt is %v from now, want within the TTL", until)
+	}
+}
@@ -1,18 +1,22 @@
 package chat
 
 import (
+	"errors"
 	"net/http"
 
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
 	"github.com/gin-gonic/gin"
+	validator "github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
 type ChatController struct {
 	chatService ChatService
+	chatSocket  *ChatSocket
 }
 
-func NewChatController(ChatService ChatService) *ChatController {
-	return &ChatController{chatService: ChatService}
+func NewChatController(ChatService ChatService, chatSocket *ChatSocket) *ChatController {
+	return &ChatController{chatService: ChatService, chatSocket: chatSocket}
 }
 
 func (cc *ChatController) GetChatMessages(c *gin.Context) {
@@ -23,28 +27,142 @@ func (cc *ChatController) GetChatMessages(c *gin.Context) {
 		return
 	}
 
-	chatMessages, err := cc.chatService.ReadAllChatMessages(c.Request.Context(), sessionID)
+	var query ChatMessageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
+	page, err := cc.chatService.ReadChatMessages(c.Request.Context(), sessionID, &query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, chatMessages)
+	if page.NextCursor != nil {
+		c.Header("X-Next-Cursor", page.NextCursor.String())
+	}
+	c.JSON(http.StatusOK, page.Messages)
 }
 
-func (cc *ChatController) CreateChatMessage(c *gin.Context) {
-	var chatMessage CreateChatMessageDTO
-	if err := c.ShouldBindJSON(&chatMessage); err != nil {
+// SendChatMessage saves a message and publishes it like a "message" frame on the chat socket,
+// for clients that receive session events over SSE instead. Like the socket, it requires a ticket for the session.
+func (cc *ChatController) SendChatMessage(c *gin.Context) {
+	var dto CreateChatMessageDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if _, ok := cc.chatSocket.guard.Authorize(c, dto.SessionID); !ok {
+		return
+	}
+	if err := ValidateClientMessage(&dto); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	err := cc.chatService.CreateChatMessage(c.Request.Context(), &chatMessage)
+	chatMessage, err := cc.chatService.CreateChatMessage(c.Request.Context(), &dto)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	cc.chatSocket.Publish(chatMessage, c.GetHeader("X-Correlation-ID"))
+	c.JSON(http.StatusCreated, chatMessage)
+}
+
+func (cc *ChatController) EditChatMessage(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto EditChatMessageDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chatMessage, err := cc.chatService.EditChatMessage(c.Request.Context(), messageID, &dto)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// The edited prompt is broadcast and answered like a freshly sent message
+	cc.chatSocket.Publish(chatMessage, "")
+
 	c.JSON(http.StatusOK, chatMessage)
 }
+
+func (cc *ChatController) RegenerateChatMessage(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prompt, branchID, err := cc.chatService.ForkForRegeneration(c.Request.Context(), messageID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	cc.chatSocket.Regenerate(prompt, branchID)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "regeneration started"})
+}
+
+func (cc *ChatController) GetBranches(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	branches, err := cc.chatService.ReadBranches(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, branches)
+}
+
+func (cc *ChatController) SwitchActiveBranch(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto SwitchBranchDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := cc.chatService.SwitchActiveBranch(c.Request.Context(), sessionID, dto.BranchID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"active_branch_id": dto.BranchID})
+}
+
+func chatErrorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, ErrMessageNotFound), errors.Is(err, ErrBranchNotFound), errors.Is(err, ErrSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidFork), errors.As(err, &validationErrors):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrRoleNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, session.ErrSessionReadOnly):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
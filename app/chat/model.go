@@ -6,6 +6,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// Chat socket specific event type, in addition to the hub envelope event types
+const ChatEventFeedback = "feedback"
+
 const (
 	ChatMessageTypeNormal          = 0
 	ChatMessageTypeToolSelection   = 1
@@ -20,19 +23,60 @@ const (
 )
 
 type ChatMessage struct {
-	ID            uuid.UUID   `json:"id"`
-	SessionID     uuid.UUID   `json:"session_id"`
-	Role          string      `json:"role"`
-	Message       string      `json:"message"`
-	CreatedAt     time.Time   `json:"created_at"`
-	MessageType   int         `json:"message_type"`
-	LinkedToolIDs []uuid.UUID `json:"linked_tool_ids"`
+	ID               uuid.UUID   `json:"id"`
+	Seq              int64       `json:"seq"`
+	SessionID        uuid.UUID   `json:"session_id"`
+	Role             string      `json:"role"`
+	Message          string      `json:"message"`
+	CreatedAt        time.Time   `json:"created_at"`
+	MessageType      int         `json:"message_type"`
+	LinkedToolIDs    []uuid.UUID `json:"linked_tool_ids"`
+	ParentID         *uuid.UUID  `json:"parent_id"`
+	BranchID         *uuid.UUID  `json:"branch_id"`
+	PromptTemplateID *uuid.UUID  `json:"prompt_template_id"`
 }
 
 type CreateChatMessageDTO struct {
-	SessionID     uuid.UUID   `json:"session_id"`
+	SessionID     uuid.UUID   `json:"session_id" validate:"required"`
 	Role          string      `json:"role"`
-	Message       string      `json:"message"`
-	MessageType   int         `json:"message_type"`
+	Message       string      `json:"message" validate:"required"`
+	MessageType   int         `json:"message_type" validate:"min=0,max=3"`
 	LinkedToolIDs []uuid.UUID `json:"linked_tool_ids"`
+	// Set by the server for assistant messages, never by clients
+	PromptTemplateID *uuid.UUID `json:"-"`
+}
+
+type ChatBranch struct {
+	ID             uuid.UUID  `json:"id"`
+	SessionID      uuid.UUID  `json:"session_id"`
+	ParentBranchID *uuid.UUID `json:"parent_branch_id"`
+	ForkMessageID  *uuid.UUID `json:"fork_message_id"`
+	MessageCount   int        `json:"message_count"`
+	IsActive       bool       `json:"is_active"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type EditChatMessageDTO struct {
+	Message string `json:"message" validate:"required"`
+}
+
+type SwitchBranchDTO struct {
+	BranchID uuid.UUID `json:"branch_id" validate:"required"`
+}
+
+// ChatMessageQuery is bound from the query of the chat history. Before and After are message IDs;
+// without either, the latest messages are returned.
+type ChatMessageQuery struct {
+	Before      string   `form:"before" validate:"omitempty,uuid,excluded_with=After"`
+	After       string   `form:"after" validate:"omitempty,uuid"`
+	Limit       int      `form:"limit" validate:"omitempty,min=1,max=500"`
+	Role        []string `form:"role" validate:"omitempty,dive,oneof=user assistant system"`
+	MessageType []int    `form:"message_type" validate:"omitempty,dive,min=0,max=3"`
+}
+
+// ChatMessagePage holds messages oldest first. NextCursor is the message to pass as the same
+// before or after parameter for the following page, and nil when there is none.
+type ChatMessagePage struct {
+	Messages   []*ChatMessage
+	NextCursor *uuid.UUID
 }
@@ -3,21 +3,32 @@ package chat
 import (
 	"context"
 
+	"aigendrug.com/aigendrug-cid-2025-server/app/assignment"
+	"aigendrug.com/aigendrug-cid-2025-server/app/auth"
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/inflight"
+	"aigendrug.com/aigendrug-cid-2025-server/app/presence"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func SetupChatRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool) {
+func SetupChatRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool, chatHub *hub.Hub, tracker *presence.Tracker, guard *auth.SocketGuard, operations *inflight.Tracker, assignments *assignment.Workflow) {
 	chatService := NewChatService(c, db)
-	chatController := NewChatController(chatService)
+	chatSocket := NewChatSocket(db, chatHub, tracker, guard, operations, assignments)
+	chatController := NewChatController(chatService, chatSocket)
 
 	chatRoutes := router.Group("/v1/chat")
 	{
 		chatRoutes.GET("/message/:sessionID", chatController.GetChatMessages)
-		chatRoutes.POST("/message", chatController.CreateChatMessage)
+		// Older clients still post here; it is the same ticket-checked endpoint as /session/messages
+		chatRoutes.POST("/message", chatController.SendChatMessage)
+		chatRoutes.POST("/message/:messageID/edit", chatController.EditChatMessage)
+		chatRoutes.POST("/message/:messageID/regenerate", chatController.RegenerateChatMessage)
 
-		chatRoutes.GET("/session/ws", func(ctx *gin.Context) {
-			WebSocketHandler(ctx, db)
-		})
+		chatRoutes.GET("/branches/:sessionID", chatController.GetBranches)
+		chatRoutes.PUT("/branches/:sessionID/active", chatController.SwitchActiveBranch)
+
+		chatRoutes.GET("/session/ws", chatSocket.WebSocketHandler)
+		chatRoutes.POST("/session/messages", chatController.SendChatMessage)
 	}
 }
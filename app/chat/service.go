@@ -2,16 +2,59 @@ package chat
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
 	"time"
 
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/database"
+	validator "github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var ErrMessageNotFound = errors.New("chat message not found")
+var ErrBranchNotFound = errors.New("chat branch not found")
+var ErrSessionNotFound = session.ErrSessionNotFound
+var ErrInvalidFork = errors.New("message cannot be forked")
+var ErrRoleNotAllowed = errors.New("clients may only send user messages")
+
+const defaultMessagePageSize = 100
+
+const chatMessageColumns = `id, seq, session_id, role, message, created_at, message_type, linked_tool_ids, parent_id, branch_id, prompt_template_id`
+
 type ChatService interface {
-	ReadAllChatMessages(rctx context.Context, sessionID uuid.UUID) ([]*ChatMessage, error)
-	CreateChatMessage(rctx context.Context, chatMessage *CreateChatMessageDTO) error
+	ReadChatMessages(rctx context.Context, sessionID uuid.UUID, query *ChatMessageQuery) (*ChatMessagePage, error)
+	CreateChatMessage(rctx context.Context, chatMessage *CreateChatMessageDTO) (*ChatMessage, error)
+	ReadChatMessage(rctx context.Context, id uuid.UUID) (*ChatMessage, error)
+	ReadMissedChatMessages(rctx context.Context, sessionID uuid.UUID, cursor *hub.ResumeCursor, limit int) ([]*ChatMessage, error)
+	EditChatMessage(rctx context.Context, id uuid.UUID, dto *EditChatMessageDTO) (*ChatMessage, error)
+	ReplyToChatMessage(rctx context.Context, parentID uuid.UUID, branchID uuid.UUID, chatMessage *CreateChatMessageDTO) (*ChatMessage, error)
+	ForkForRegeneration(rctx context.Context, id uuid.UUID) (*ChatMessage, uuid.UUID, error)
+	ReadBranches(rctx context.Context, sessionID uuid.UUID) ([]*ChatBranch, error)
+	SwitchActiveBranch(rctx context.Context, sessionID uuid.UUID, branchID uuid.UUID) error
+}
+
+// ValidateClientMessage checks a message sent by a client before it is saved.
+// Assistant and system messages are only ever written by the server, so the role defaults to and must be user.
+func ValidateClientMessage(dto *CreateChatMessageDTO) error {
+	if dto.Role == "" {
+		dto.Role = ChatRoleUser
+	}
+	if dto.Role != ChatRoleUser {
+		return ErrRoleNotAllowed
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return fmt.Errorf("chat message validation failed: %w", err)
+	}
+	return nil
 }
 
 type chatService struct {
@@ -23,62 +66,474 @@ func NewChatService(c context.Context, db *pgxpool.Pool) ChatService {
 	return &chatService{ctx: c, db: db}
 }
 
-func (s *chatService) ReadAllChatMessages(rctx context.Context, sessionID uuid.UUID) ([]*ChatMessage, error) {
+// ReadChatMessages returns one page of the messages on the session's active branch, ordered by
+// (created_at, id). Pages are read backwards from the latest message with Before, or forwards with After.
+func (s *chatService) ReadChatMessages(rctx context.Context, sessionID uuid.UUID, query *ChatMessageQuery) (*ChatMessagePage, error) {
+	validate := validator.New()
+	if err := validate.Struct(query); err != nil {
+		return nil, fmt.Errorf("chat message query validation failed: %w", err)
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultMessagePageSize
+	}
+	forward := query.After != ""
+
+	return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*ChatMessagePage, error) {
+		_, leafID, err := activeLeaf(rctx, tx, sessionID, false)
+		if err != nil {
+			return nil, err
+		}
+		if leafID == nil {
+			return &ChatMessagePage{Messages: []*ChatMessage{}}, nil
+		}
+
+		conditions := []string{"TRUE"}
+		args := []interface{}{*leafID}
+		arg := func(value interface{}) string {
+			args = append(args, value)
+			return fmt.Sprintf("$%d", len(args))
+		}
+
+		if len(query.Role) > 0 {
+			conditions = append(conditions, "role = ANY("+arg(query.Role)+")")
+		}
+		if len(query.MessageType) > 0 {
+			conditions = append(conditions, "message_type = ANY("+arg(query.MessageType)+")")
+		}
+		if cursorID := query.Before + query.After; cursorID != "" {
+			cursor, err := readChatMessage(rctx, tx, uuid.MustParse(cursorID))
+			if err == nil && cursor.SessionID != sessionID {
+				err = ErrMessageNotFound
+			}
+			if err != nil {
+				return nil, err
+			}
+			comparison := "<"
+			if forward {
+				comparison = ">"
+			}
+			conditions = append(conditions, "(created_at, id) "+comparison+" ("+arg(cursor.CreatedAt)+", "+arg(cursor.ID)+")")
+		}
+
+		direction := "DESC"
+		if forward {
+			direction = "ASC"
+		}
+		rows, err := tx.Query(rctx, `
+            WITH RECURSIVE path AS (
+                SELECT `+chatMessageColumns+`
+                FROM chat_messages
+                WHERE id = $1
+                UNION ALL
+                SELECT m.id, m.seq, m.session_id, m.role, m.message, m.created_at, m.message_type, m.linked_tool_ids, m.parent_id, m.branch_id, m.prompt_template_id
+                FROM chat_messages m
+                JOIN path p ON m.id = p.parent_id
+            )
+            SELECT `+chatMessageColumns+`
+            FROM path
+            WHERE `+strings.Join(conditions, " AND ")+`
+            ORDER BY created_at `+direction+`, id `+direction+`
+            LIMIT `+arg(limit+1), args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		messages := []*ChatMessage{}
+		for rows.Next() {
+			chatMessage, err := scanChatMessage(rows)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, chatMessage)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		// One extra row tells whether there is a next page; pages are always returned oldest first
+		page := &ChatMessagePage{Messages: messages}
+		if len(messages) > limit {
+			page.Messages = messages[:limit]
+			page.NextCursor = &page.Messages[limit-1].ID
+		}
+		if !forward {
+			slices.Reverse(page.Messages)
+		}
+		return page, nil
+	})
+}
+
+// CreateChatMessage appends the message to the end of the session's active branch.
+func (s *chatService) CreateChatMessage(rctx context.Context, chatMessage *CreateChatMessageDTO) (*ChatMessage, error) {
+	return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*ChatMessage, error) {
+		return AppendChatMessage(rctx, tx, chatMessage)
+	})
+}
+
+// AppendChatMessage appends the message to the end of the session's active branch within tx, for callers
+// that add messages together with other changes.
+func AppendChatMessage(rctx context.Context, tx database.DbExecutor, chatMessage *CreateChatMessageDTO) (*ChatMessage, error) {
+	branchID, leafID, err := activeLeaf(rctx, tx, chatMessage.SessionID, true)
+	if err != nil {
+		return nil, err
+	}
+	return insertChatMessage(rctx, tx, chatMessage, leafID, branchID)
+}
+
+// ReplyToChatMessage adds the message right after parentID on branchID. Unlike CreateChatMessage it does not
+// follow the active leaf, so an answer stays attached to its prompt when the user moves on meanwhile.
+func (s *chatService) ReplyToChatMessage(rctx context.Context, parentID uuid.UUID, branchID uuid.UUID, chatMessage *CreateChatMessageDTO) (*ChatMessage, error) {
+	return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*ChatMessage, error) {
+		if _, err := session.LockWritableSession(rctx, tx, chatMessage.SessionID); err != nil {
+			return nil, err
+		}
+		return insertChatMessage(rctx, tx, chatMessage, &parentID, branchID)
+	})
+}
+
+func (s *chatService) ReadChatMessage(rctx context.Context, id uuid.UUID) (*ChatMessage, error) {
+	return readChatMessage(rctx, s.db, id)
+}
+
+// ReadMissedChatMessages returns up to limit messages of every branch of the session that come after cursor, in seq order.
+func (s *chatService) ReadMissedChatMessages(rctx context.Context, sessionID uuid.UUID, cursor *hub.ResumeCursor, limit int) ([]*ChatMessage, error) {
+	afterSeq := cursor.LastSeq
+	if cursor.LastMessageID != nil {
+		err := s.db.QueryRow(rctx, "SELECT seq FROM chat_messages WHERE id = $1 AND session_id = $2", *cursor.LastMessageID, sessionID).Scan(&afterSeq)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMessageNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := s.db.Query(rctx, `
+        SELECT `+chatMessageColumns+`
+        FROM chat_messages
+        WHERE session_id = $1 AND seq > $2 AND ($3::timestamp IS NULL OR created_at > $3)
+        ORDER BY seq
+        LIMIT $4
+    `, sessionID, afterSeq, cursor.Since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chatMessages := []*ChatMessage{}
+	for rows.Next() {
+		chatMessage, err := scanChatMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		chatMessages = append(chatMessages, chatMessage)
+	}
+	return chatMessages, rows.Err()
+}
+
+// EditChatMessage forks a new branch next to a user message and places the edited copy on it.
+func (s *chatService) EditChatMessage(rctx context.Context, id uuid.UUID, dto *EditChatMessageDTO) (*ChatMessage, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("chat message validation failed: %w", err)
+	}
+
+	return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*ChatMessage, error) {
+		original, err := readChatMessage(rctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if original.Role != ChatRoleUser {
+			return nil, fmt.Errorf("%w: only user messages can be edited", ErrInvalidFork)
+		}
+
+		if _, _, err := activeLeaf(rctx, tx, original.SessionID, true); err != nil {
+			return nil, err
+		}
+
+		branchID, err := createBranch(rctx, tx, original.SessionID, original.BranchID, original.ParentID)
+		if err != nil {
+			return nil, err
+		}
+
+		return insertChatMessage(rctx, tx, &CreateChatMessageDTO{
+			SessionID:     original.SessionID,
+			Role:          original.Role,
+			Message:       dto.Message,
+			MessageType:   original.MessageType,
+			LinkedToolIDs: original.LinkedToolIDs,
+		}, original.ParentID, branchID)
+	})
+}
+
+// ForkForRegeneration forks a new branch after the user message that produced an assistant message
+// and returns that user message and the new branch, so a fresh answer can be added to it.
+func (s *chatService) ForkForRegeneration(rctx context.Context, id uuid.UUID) (*ChatMessage, uuid.UUID, error) {
+	var prompt *ChatMessage
+	branchID, err := database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (uuid.UUID, error) {
+		answer, err := readChatMessage(rctx, tx, id)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if answer.Role != ChatRoleAssistant {
+			return uuid.Nil, fmt.Errorf("%w: only assistant messages can be regenerated", ErrInvalidFork)
+		}
+		if answer.ParentID == nil {
+			return uuid.Nil, fmt.Errorf("%w: assistant message has no prompt to regenerate from", ErrInvalidFork)
+		}
+
+		prompt, err = readChatMessage(rctx, tx, *answer.ParentID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if prompt.Role != ChatRoleUser {
+			return uuid.Nil, fmt.Errorf("%w: assistant message does not follow a user message", ErrInvalidFork)
+		}
+
+		if _, _, err := activeLeaf(rctx, tx, answer.SessionID, true); err != nil {
+			return uuid.Nil, err
+		}
+
+		return createBranch(rctx, tx, answer.SessionID, answer.BranchID, &prompt.ID)
+	})
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	return prompt, branchID, nil
+}
+
+func (s *chatService) ReadBranches(rctx context.Context, sessionID uuid.UUID) ([]*ChatBranch, error) {
 	rows, err := s.db.Query(rctx, `
         SELECT
-            id,
-            session_id,
-            role,
-            message,
-            created_at,
-            message_type,
-            linked_tool_ids
-        FROM
-            chat_messages
-        WHERE
-            session_id = $1
-        ORDER BY created_at ASC
+            b.id,
+            b.session_id,
+            b.parent_branch_id,
+            b.fork_message_id,
+            (SELECT COUNT(*) FROM chat_messages m WHERE m.branch_id = b.id),
+            b.id = COALESCE(s.active_branch_id, s.id),
+            b.created_at
+        FROM chat_branches b
+        JOIN sessions s ON s.id = b.session_id
+        WHERE b.session_id = $1
+        ORDER BY b.created_at ASC
     `, sessionID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var chatMessages []*ChatMessage
+	branches := []*ChatBranch{}
+	for rows.Next() {
+		var branch ChatBranch
+		if err := rows.Scan(
+			&branch.ID,
+			&branch.SessionID,
+			&branch.ParentBranchID,
+			&branch.ForkMessageID,
+			&branch.MessageCount,
+			&branch.IsActive,
+			&branch.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		branches = append(branches, &branch)
+	}
+	return branches, rows.Err()
+}
+
+func (s *chatService) SwitchActiveBranch(rctx context.Context, sessionID uuid.UUID, branchID uuid.UUID) error {
+	tag, err := s.db.Exec(rctx, `
+        UPDATE sessions
+        SET active_branch_id = $2
+        WHERE id = $1 AND deleted_at IS NULL AND EXISTS (
+            SELECT 1 FROM chat_branches WHERE id = $2 AND session_id = $1
+        )
+    `, sessionID, branchID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrBranchNotFound
+	}
+	return nil
+}
+
+// activeLeaf returns the session's active branch and the last message on it.
+// With lock set, the session row is locked so concurrent appends are serialized.
+func activeLeaf(rctx context.Context, db database.DbExecutor, sessionID uuid.UUID, lock bool) (uuid.UUID, *uuid.UUID, error) {
+	// Locking is for adding messages, which archived and closed sessions refuse
+	if lock {
+		if _, err := session.LockWritableSession(rctx, db, sessionID); err != nil {
+			return uuid.Nil, nil, err
+		}
+	}
+
+	query := "SELECT COALESCE(active_branch_id, id) FROM sessions WHERE id = $1 AND deleted_at IS NULL"
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	var branchID uuid.UUID
+	err := db.QueryRow(rctx, query, sessionID).Scan(&branchID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	// The main branch is created lazily for sessions that never had one
+	if branchID == sessionID && lock {
+		_, err := db.Exec(rctx, `
+            INSERT INTO chat_branches (id, session_id, created_at)
+            VALUES ($1, $1, $2)
+            ON CONFLICT (id) DO NOTHING
+        `, sessionID, time.Now())
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+	}
+
+	var leafID *uuid.UUID
+	err = db.QueryRow(rctx, `
+        SELECT id FROM chat_messages
+        WHERE branch_id = $1
+        ORDER BY created_at DESC, id DESC
+        LIMIT 1
+    `, branchID).Scan(&leafID)
+	if err == nil {
+		return branchID, leafID, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, nil, err
+	}
+
+	// An empty branch continues from the message it was forked at
+	err = db.QueryRow(rctx, "SELECT fork_message_id FROM chat_branches WHERE id = $1", branchID).Scan(&leafID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, nil, err
+	}
+	return branchID, leafID, nil
+}
+
+// readPath walks parent links from leafID back to the root and returns the messages oldest first.
+func readPath(rctx context.Context, db database.DbExecutor, leafID uuid.UUID) ([]*ChatMessage, error) {
+	rows, err := db.Query(rctx, `
+        WITH RECURSIVE path AS (
+            SELECT `+chatMessageColumns+`, 0 AS depth
+            FROM chat_messages
+            WHERE id = $1
+            UNION ALL
+            SELECT m.id, m.seq, m.session_id, m.role, m.message, m.created_at, m.message_type, m.linked_tool_ids, m.parent_id, m.branch_id, m.prompt_template_id, p.depth + 1
+            FROM chat_messages m
+            JOIN path p ON m.id = p.parent_id
+        )
+        SELECT `+chatMessageColumns+`
+        FROM path
+        ORDER BY depth DESC
+    `, leafID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chatMessages := []*ChatMessage{}
 	for rows.Next() {
-		var chatMessage ChatMessage
-		err := rows.Scan(
-			&chatMessage.ID,
-			&chatMessage.SessionID,
-			&chatMessage.Role,
-			&chatMessage.Message,
-			&chatMessage.CreatedAt,
-			&chatMessage.MessageType,
-			&chatMessage.LinkedToolIDs,
-		)
+		chatMessage, err := scanChatMessage(rows)
 		if err != nil {
 			return nil, err
 		}
-		chatMessages = append(chatMessages, &chatMessage)
+		chatMessages = append(chatMessages, chatMessage)
+	}
+	return chatMessages, rows.Err()
+}
+
+func readChatMessage(rctx context.Context, db database.DbExecutor, id uuid.UUID) (*ChatMessage, error) {
+	chatMessage, err := scanChatMessage(db.QueryRow(rctx, "SELECT "+chatMessageColumns+" FROM chat_messages WHERE id = $1", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMessageNotFound
 	}
-	return chatMessages, nil
+	return chatMessage, err
 }
 
-func (s *chatService) CreateChatMessage(rctx context.Context, chatMessage *CreateChatMessageDTO) error {
-	newUUID := uuid.New()
-	_, err := s.db.Exec(rctx, `
+func createBranch(rctx context.Context, db database.DbExecutor, sessionID uuid.UUID, parentBranchID *uuid.UUID, forkMessageID *uuid.UUID) (uuid.UUID, error) {
+	branchID := uuid.New()
+	_, err := db.Exec(rctx, `
+        INSERT INTO chat_branches (id, session_id, parent_branch_id, fork_message_id, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `, branchID, sessionID, parentBranchID, forkMessageID, time.Now())
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	_, err = db.Exec(rctx, "UPDATE sessions SET active_branch_id = $2 WHERE id = $1", sessionID, branchID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return branchID, nil
+}
+
+func insertChatMessage(rctx context.Context, db database.DbExecutor, dto *CreateChatMessageDTO, parentID *uuid.UUID, branchID uuid.UUID) (*ChatMessage, error) {
+	chatMessage := &ChatMessage{
+		ID:               uuid.New(),
+		SessionID:        dto.SessionID,
+		Role:             dto.Role,
+		Message:          dto.Message,
+		CreatedAt:        time.Now(),
+		MessageType:      dto.MessageType,
+		LinkedToolIDs:    dto.LinkedToolIDs,
+		ParentID:         parentID,
+		BranchID:         &branchID,
+		PromptTemplateID: dto.PromptTemplateID,
+	}
+
+	err := db.QueryRow(rctx, `
         INSERT INTO chat_messages
-            (id, session_id, role, message, created_at, message_type, linked_tool_ids)
+            (id, session_id, role, message, created_at, message_type, linked_tool_ids, parent_id, branch_id, prompt_template_id)
         VALUES
-            ($1, $2, $3, $4, $5, $6, $7)
+            ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING seq
     `,
-		newUUID,
+		chatMessage.ID,
 		chatMessage.SessionID,
 		chatMessage.Role,
 		chatMessage.Message,
-		time.Now(),
+		chatMessage.CreatedAt,
 		chatMessage.MessageType,
 		chatMessage.LinkedToolIDs,
+		chatMessage.ParentID,
+		chatMessage.BranchID,
+		chatMessage.PromptTemplateID,
+	).Scan(&chatMessage.Seq)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.TouchSession(rctx, db, chatMessage.SessionID, chatMessage.CreatedAt); err != nil {
+		return nil, err
+	}
+	return chatMessage, nil
+}
+
+func scanChatMessage(row pgx.Row) (*ChatMessage, error) {
+	var chatMessage ChatMessage
+	err := row.Scan(
+		&chatMessage.ID,
+		&chatMessage.Seq,
+		&chatMessage.SessionID,
+		&chatMessage.Role,
+		&chatMessage.Message,
+		&chatMessage.CreatedAt,
+		&chatMessage.MessageType,
+		&chatMessage.LinkedToolIDs,
+		&chatMessage.ParentID,
+		&chatMessage.BranchID,
+		&chatMessage.PromptTemplateID,
 	)
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return &chatMessage, nil
 }
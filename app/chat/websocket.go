@@ -2,221 +2,425 @@ package chat
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"sync"
+	"strings"
 
-	toolrouter "aigendrug.com/aigendrug-cid-2025-server/tool-router"
+	"aigendrug.com/aigendrug-cid-2025-server/app/assignment"
+	"aigendrug.com/aigendrug-cid-2025-server/app/auth"
+	"aigendrug.com/aigendrug-cid-2025-server/app/evaluation"
+	"aigendrug.com/aigendrug-cid-2025-server/app/feedback"
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/inflight"
+	"aigendrug.com/aigendrug-cid-2025-server/app/presence"
+	"aigendrug.com/aigendrug-cid-2025-server/app/prompt"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/openai/openai-go"
 )
 
 /*
-WebSocketHandler is a handler function for Gin routes that upgrades the HTTP connection to a WebSocket connection.
-It reads messages from the client and broadcast them to all other clients in the same session.
+ChatSocket upgrades HTTP connections to WebSocket connections and joins them to the session's hub.
+Every frame in both directions is a hub.Envelope.
 
-HandleMessages is a function that reads messages from the broadcast channel and sends them to all clients in the session.
-If the message is from the user, it generates an AI response and sends it to all clients.
-HandleMessages is called as a goroutine in the main function and runs in the context of the main gin server.
+Clients send "message" frames carrying a CreateChatMessageDTO, "feedback" frames carrying a CreateFeedbackDTO
+and "typing" frames carrying a presence.TypingDTO. Each accepted frame is answered with an "ack" to the sender, and each rejected or failed one with an "error".
+Connections must come from an allowed origin, carry a ticket for the session (see auth.SocketGuard) and name an
+existing session; messages are always saved in that session and only with the user role.
+Saved messages are broadcast to every client of the session as "message" frames.
+If the message is from the user, an AI response is streamed as "delta" frames in its own goroutine and
+broadcast as a "message" once saved, so slow AI calls never hold up other broadcasts.
+The tool selected for the answer is suggested as the session's tool; see assignment.Workflow.
 
-WebSocketHandler and HandleMessages use a shared map called sessionClients to manage WebSocket clients in the same session.
+Every connection is a participant of the session, named by the "name" query parameter; see presence.Tracker.
 */
 
-// Websocket Upgrader
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+type ChatSocket struct {
+	db          *pgxpool.Pool
+	hub         *hub.Hub
+	presence    *presence.Tracker
+	guard       *auth.SocketGuard
+	operations  *inflight.Tracker
+	assignments *assignment.Workflow
 }
 
-var sessionClients = make(map[string]map[*websocket.Conn]bool) // Dynamic map of sessionID to clients
-var broadcast = make(chan ChatMessage)                         // Sync channel for broadcasting messages
-var mutex = sync.Mutex{}                                       // Mutex for sessionClients
+func NewChatSocket(db *pgxpool.Pool, h *hub.Hub, tracker *presence.Tracker, guard *auth.SocketGuard, operations *inflight.Tracker, assignments *assignment.Workflow) *ChatSocket {
+	return &ChatSocket{db: db, hub: h, presence: tracker, guard: guard, operations: operations, assignments: assignments}
+}
+
+type aiResponse struct {
+	Message          string
+	SelectedToolID   uuid.UUID
+	PromptTemplateID *uuid.UUID
+}
 
 // Replace with AgentResponse from aigendrug ai service
-func generateAIResponse(message string) (string, string) {
-	trs := toolrouter.NewToolRouterService(context.Background())
-	tool, err := trs.SelectTool(message)
+// ctx only bounds the OpenAI call; everything is read before it starts.
+func generateAIResponse(ctx context.Context, db *pgxpool.Pool, userMsg ChatMessage, onDelta func(content string)) (*aiResponse, error) {
+	selectedTool, err := tool.NewToolService(ctx, db).SelectTool(ctx, userMsg.Message)
 	if err != nil {
-		log.Println("Failed to select tool:", err)
-		return "", ""
+		return nil, err
 	}
 
-	client := openai.NewClient()
-	chatCompletion, err := client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(fmt.Sprintf(`
-				You are a helpful assistant that finds the best tool for the user. 
-				Extract the user intention of user and find the best tool for the user.
-				Selected Tool is %s.
-				Tell user about intention and why this tool is selected.
-				Keep kind and helpful.
-			`, tool.ToolName)),
-			openai.UserMessage(message),
-		}),
-		Model: openai.F(openai.ChatModelGPT4o),
-	})
+	currentSession, err := session.NewSessionService(ctx, db).ReadSession(ctx, userMsg.SessionID)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 
-	return chatCompletion.Choices[0].Message.Content, tool.ToolID.String()
-}
-
-func WebSocketHandler(c *gin.Context, db *pgxpool.Pool) {
-	sessionID := c.Query("sessionID")
-	if sessionID == "" {
-		c.JSON(400, gin.H{"error": "sessionID is required"})
-		return
+	toolInterface, err := json.Marshal(selectedTool.ProviderInterface)
+	if err != nil {
+		return nil, err
 	}
 
-	// Upgrade the HTTP connection to a WebSocket connection
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	systemPrompt, err := prompt.NewPromptService(ctx, db).RenderForTool(ctx, selectedTool.ID, &prompt.PromptVariables{
+		ToolName:        selectedTool.Name,
+		ToolDescription: selectedTool.Description,
+		ToolInterface:   string(toolInterface),
+		SessionName:     currentSession.Name,
+	})
 	if err != nil {
-		log.Println("WebSocket Upgrade Error:", err)
-		return
+		return nil, err
 	}
-	defer conn.Close()
 
-	// Lock mutex of sessionClients and add the new client.
-	mutex.Lock()
-	if sessionClients[sessionID] == nil {
-		sessionClients[sessionID] = make(map[*websocket.Conn]bool)
+	// Only the branch leading to the user message is sent as context
+	history, err := readPath(ctx, db, userMsg.ID)
+	if err != nil {
+		return nil, err
 	}
-	sessionClients[sessionID][conn] = true
-	mutex.Unlock()
 
-	// Read messages from the client and broadcast them to all other clients
-	for {
-		var msg CreateChatMessageDTO
-		err := conn.ReadJSON(&msg)
-		// If there is an error, remove the client from the sessionClients map and break the loop
-		if err != nil {
-			log.Println("Read Error:", err)
-			mutex.Lock()
-			delete(sessionClients[sessionID], conn)
-			mutex.Unlock()
-			break
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(systemPrompt.Text),
+	}
+	for _, message := range history {
+		switch message.Role {
+		case ChatRoleUser:
+			messages = append(messages, openai.UserMessage(message.Message))
+		case ChatRoleAssistant:
+			messages = append(messages, openai.AssistantMessage(message.Message))
 		}
+	}
 
-		// Save the message to the database and broadcast it to all clients
-		err = saveChatMessageToDB(db, &msg)
-		if err != nil {
-			log.Println("DB Save Error:", err)
-			continue
-		}
+	client := openai.NewClient()
+	stream := client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
+		Messages: openai.F(messages),
+		Model:    openai.F(openai.ChatModelGPT4o),
+	})
+	defer stream.Close()
 
-		broadcast <- ChatMessage{
-			SessionID:     msg.SessionID,
-			Role:          msg.Role,
-			Message:       msg.Message,
-			MessageType:   msg.MessageType,
-			LinkedToolIDs: msg.LinkedToolIDs,
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			onDelta(chunk.Choices[0].Delta.Content)
 		}
 	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	if len(acc.Choices) == 0 {
+		return nil, fmt.Errorf("AI response has no choices")
+	}
+
+	return &aiResponse{
+		Message:          acc.Choices[0].Message.Content,
+		SelectedToolID:   selectedTool.ID,
+		PromptTemplateID: systemPrompt.TemplateID,
+	}, nil
 }
 
-func saveChatMessageToDB(db *pgxpool.Pool, msg *CreateChatMessageDTO) error {
-	newUUID := uuid.New()
+func (cs *ChatSocket) WebSocketHandler(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Query("sessionID"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "sessionID must be a valid session id"})
+		return
+	}
+	if _, err := session.NewSessionService(c.Request.Context(), cs.db).ReadSession(c.Request.Context(), sessionID); err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	_, err := db.Exec(context.Background(), "INSERT INTO chat_messages (id, session_id, role, message, created_at, message_type, linked_tool_ids) VALUES ($1, $2, $3, $4, now(), $5, $6)",
-		newUUID, msg.SessionID, msg.Role, msg.Message, msg.MessageType, msg.LinkedToolIDs)
+	// Reconnecting clients pass the last message they saw to receive everything they missed
+	cursor, err := hub.ParseResumeCursor(c.Request.URL.Query())
 	if err != nil {
-		return err
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
 	}
+	replay := cs.replayer(sessionID, cursor)
 
-	return nil
-}
+	// Upgrade the HTTP connection to a WebSocket connection
+	conn := cs.guard.Upgrade(c, sessionID)
+	if conn == nil {
+		return
+	}
+
+	chatService := NewChatService(context.Background(), cs.db)
+
+	name := c.DefaultQuery("name", "Anonymous")
+	var participant *presence.Participant
+	options := hub.SocketOptions{
+		Replay: replay,
+		OnJoin: func(client *hub.Client) {
+			participant = cs.presence.Join(client, name)
+		},
+		OnLeave: func(client *hub.Client) {
+			cs.presence.Leave(participant)
+		},
+	}
 
-// Read messages from the broadcast channel and send them to all clients in the session
-func HandleMessages(db *pgxpool.Pool) {
-	for {
-		msg := <-broadcast
-
-		// Lock the mutex and check if the session exists
-		mutex.Lock()
-		clients, exists := sessionClients[msg.SessionID.String()]
-		if !exists {
-			mutex.Unlock()
-			continue
+	cs.hub.ServeWebSocket(conn, sessionID.String(), options, func(client *hub.Client, data []byte) {
+		envelope, err := hub.ParseEnvelope(data)
+		if err != nil {
+			cs.reject(client, envelope, hub.ErrorCodeInvalidFrame, err)
+			return
 		}
 
-		// For each client in the session, send the message
-		for client := range clients {
-			err := client.WriteJSON(msg)
+		switch envelope.Type {
+		case hub.EventMessage:
+			var dto CreateChatMessageDTO
+			if err := envelope.DecodePayload(&dto); err != nil {
+				cs.reject(client, envelope, hub.ErrorCodeInvalidPayload, err)
+				return
+			}
+
+			// Clients can only post into the session they are connected to, and only as the user
+			dto.SessionID = sessionID
+			if err := ValidateClientMessage(&dto); err != nil {
+				cs.reject(client, envelope, hub.ErrorCodeValidationFailed, err)
+				return
+			}
+
+			// Save the message to the database and broadcast it to all clients
+			msg, err := chatService.CreateChatMessage(context.Background(), &dto)
 			if err != nil {
-				log.Println("Send Error:", err)
-				client.Close()
-				delete(clients, client)
+				log.Println("DB Save Error:", err)
+				cs.reject(client, envelope, saveErrorCode(err), err)
+				return
 			}
-		}
-		mutex.Unlock()
-
-		// If the message is from the user, generate an AI response and send it to all clients
-		if msg.Role == ChatRoleUser {
-			aiResponse, selectedToolID := generateAIResponse(msg.Message)
-
-			aiMsg := ChatMessage{
-				SessionID:     msg.SessionID,
-				Role:          ChatRoleAssistant,
-				Message:       aiResponse,
-				MessageType:   msg.MessageType,
-				LinkedToolIDs: msg.LinkedToolIDs,
+
+			cs.ack(client, envelope, msg.ID.String())
+			cs.Publish(msg, envelope.ID)
+
+			// Sending a message ends typing
+			if err := cs.presence.SetTyping(participant, false); err != nil {
+				log.Println("Presence State Error:", err)
 			}
-			systemMsg := ChatMessage{
-				SessionID:     msg.SessionID,
-				Role:          ChatRoleSystem,
-				Message:       selectedToolID,
-				MessageType:   ChatMessageTypeToolSelection,
-				LinkedToolIDs: msg.LinkedToolIDs,
+		case ChatEventFeedback:
+			var dto feedback.CreateFeedbackDTO
+			if err := envelope.DecodePayload(&dto); err != nil {
+				cs.reject(client, envelope, hub.ErrorCodeInvalidPayload, err)
+				return
 			}
 
-			err := saveChatMessageToDB(db, &CreateChatMessageDTO{
-				SessionID:     aiMsg.SessionID,
-				Role:          aiMsg.Role,
-				Message:       aiMsg.Message,
-				MessageType:   aiMsg.MessageType,
-				LinkedToolIDs: aiMsg.LinkedToolIDs,
-			})
+			// Feedback can only be given on messages of the connected session
+			ratedMsg, err := chatService.ReadChatMessage(context.Background(), dto.MessageID)
+			if err == nil && ratedMsg.SessionID != sessionID {
+				err = fmt.Errorf("%w in this session", ErrMessageNotFound)
+			}
 			if err != nil {
-				log.Println("Failed to save AI response:", err)
-				continue
+				cs.reject(client, envelope, hub.ErrorCodeValidationFailed, err)
+				return
 			}
 
-			err = saveChatMessageToDB(db, &CreateChatMessageDTO{
-				SessionID:     systemMsg.SessionID,
-				Role:          systemMsg.Role,
-				Message:       systemMsg.Message,
-				MessageType:   systemMsg.MessageType,
-				LinkedToolIDs: systemMsg.LinkedToolIDs,
-			})
+			// Save the feedback and let every client in the session know about it
+			savedFeedback, err := feedback.NewFeedbackService(context.Background(), cs.db).CreateFeedback(context.Background(), &dto)
 			if err != nil {
-				log.Println("Failed to save tool ID:", err)
-				continue
+				log.Println("Feedback Save Error:", err)
+				cs.reject(client, envelope, hub.ErrorCodeSaveFailed, err)
+				return
+			}
+
+			cs.ack(client, envelope, savedFeedback.ID.String())
+			cs.broadcast(savedFeedback.SessionID, ChatEventFeedback, envelope.ID, savedFeedback)
+		case presence.EventTyping:
+			var dto presence.TypingDTO
+			if err := envelope.DecodePayload(&dto); err != nil {
+				cs.reject(client, envelope, hub.ErrorCodeInvalidPayload, err)
+				return
 			}
 
-			// Lock the mutex and send the AI response and finish message to all clients
-			mutex.Lock()
-			for client := range clients {
-				err := client.WriteJSON(aiMsg)
-				if err != nil {
-					log.Println("Send Error:", err)
-					client.Close()
-					delete(clients, client)
-				}
-
-				err = client.WriteJSON(systemMsg)
-				if err != nil {
-					log.Println("Send Error:", err)
-					client.Close()
-					delete(clients, client)
-				}
+			if err := cs.presence.SetTyping(participant, dto.Typing); err != nil {
+				cs.reject(client, envelope, hub.ErrorCodeSaveFailed, err)
+				return
 			}
-			mutex.Unlock()
+			cs.ack(client, envelope, "")
+		default:
+			cs.reject(client, envelope, hub.ErrorCodeUnknownType, fmt.Errorf("unknown event type %q", envelope.Type))
+		}
+	})
+}
+
+func (cs *ChatSocket) ack(client *hub.Client, envelope *hub.Envelope, resourceID string) {
+	ack, err := hub.NewEnvelope(hub.EventAck, envelope.ID, hub.AckPayload{ID: resourceID})
+	if err != nil {
+		log.Println("Ack Error:", err)
+		return
+	}
+	cs.hub.SendTo(client, ack)
+}
+
+// reject sends an error frame to the client that sent envelope, which may be nil for unparseable frames
+func (cs *ChatSocket) reject(client *hub.Client, envelope *hub.Envelope, code string, err error) {
+	correlationID := ""
+	if envelope != nil {
+		correlationID = envelope.ID
+	}
+	cs.hub.SendTo(client, hub.NewErrorEnvelope(correlationID, code, err.Error()))
+}
+
+func (cs *ChatSocket) broadcast(sessionID uuid.UUID, eventType string, correlationID string, payload any) {
+	envelope, err := hub.NewEnvelope(eventType, correlationID, payload)
+	if err == nil {
+		err = cs.hub.Broadcast(sessionID.String(), envelope)
+	}
+	if err != nil {
+		log.Println("Broadcast Error:", err)
+	}
+}
+
+// broadcastMessage sends a saved message with its seq, which lets reconnecting clients resume after it
+func (cs *ChatSocket) broadcastMessage(msg *ChatMessage, correlationID string) {
+	envelope, err := hub.NewMessageEnvelope(correlationID, msg.Seq, msg)
+	if err == nil {
+		err = cs.hub.Broadcast(msg.SessionID.String(), envelope)
+	}
+	if err != nil {
+		log.Println("Broadcast Error:", err)
+	}
+}
+
+// replayer returns nil when the client did not ask to resume
+func (cs *ChatSocket) replayer(sessionID uuid.UUID, cursor *hub.ResumeCursor) hub.Replayer {
+	if !cursor.IsSet() {
+		return nil
+	}
+
+	return func(send func(envelope *hub.Envelope) error) (int64, error) {
+		ctx := context.Background()
+		messages, err := NewChatService(ctx, cs.db).ReadMissedChatMessages(ctx, sessionID, cursor, hub.MaxReplayMessages+1)
+		if err != nil {
+			return 0, err
 		}
+		return hub.SendReplay(send, cursor, messages, func(msg *ChatMessage) int64 { return msg.Seq })
 	}
 }
+
+// saveErrorCode tells clients whether a message was refused because its session no longer takes messages
+func saveErrorCode(err error) string {
+	if errors.Is(err, session.ErrSessionReadOnly) {
+		return hub.ErrorCodeSessionReadOnly
+	}
+	return hub.ErrorCodeSaveFailed
+}
+
+func sessionErrorStatus(err error) int {
+	if errors.Is(err, session.ErrSessionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// Publish broadcasts a saved message and, if it is from the user, starts generating the AI response.
+// correlationID is the id of the client frame that created the message, if any.
+func (cs *ChatSocket) Publish(msg *ChatMessage, correlationID string) {
+	cs.broadcastMessage(msg, correlationID)
+
+	if msg.Role == ChatRoleUser {
+		go cs.respondToUserMessage(*msg, *msg.BranchID)
+	}
+}
+
+// Regenerate starts generating a new AI response to an existing user message on branchID
+func (cs *ChatSocket) Regenerate(userMsg *ChatMessage, branchID uuid.UUID) {
+	go cs.respondToUserMessage(*userMsg, branchID)
+}
+
+// respondToUserMessage streams the AI response to the session. Deltas, the final messages and any error
+// share a correlation id so clients can stitch them together. The answer is saved on branchID right after
+// msg, wherever the active branch has moved to while it was generated.
+func (cs *ChatSocket) respondToUserMessage(msg ChatMessage, branchID uuid.UUID) {
+	ctx := context.Background()
+	db := cs.db
+	chatService := NewChatService(ctx, db)
+	streamID := uuid.NewString()
+
+	operation, err := cs.operations.Start(inflight.OperationKindChatResponse, msg.SessionID, &msg.ID)
+	if err != nil {
+		cs.broadcast(msg.SessionID, hub.EventError, streamID, hub.ErrorPayload{Code: hub.ErrorCodeAIFailed, Message: err.Error()})
+		return
+	}
+	defer operation.Finish()
+
+	cs.presence.SetAgentState(msg.SessionID, presence.ParticipantKindAssistant, presence.ParticipantStateThinking)
+	defer cs.presence.SetAgentState(msg.SessionID, presence.ParticipantKindAssistant, presence.ParticipantStateIdle)
+
+	var partial strings.Builder
+	response, err := generateAIResponse(operation.Context(), db, msg, func(content string) {
+		partial.WriteString(content)
+		cs.broadcast(msg.SessionID, hub.EventDelta, streamID, hub.DeltaPayload{Content: content})
+	})
+	if err != nil && operation.Interrupted() {
+		log.Println("AI response interrupted by shutdown:", err)
+		operation.Interrupt(partial.String())
+		cs.broadcast(msg.SessionID, hub.EventError, streamID, hub.ErrorPayload{Code: hub.ErrorCodeInterrupted, Message: inflight.ErrShuttingDown.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Failed to generate AI response:", err)
+		cs.broadcast(msg.SessionID, hub.EventError, streamID, hub.ErrorPayload{Code: hub.ErrorCodeAIFailed, Message: err.Error()})
+		return
+	}
+
+	aiMsg, err := chatService.ReplyToChatMessage(ctx, msg.ID, branchID, &CreateChatMessageDTO{
+		SessionID:        msg.SessionID,
+		Role:             ChatRoleAssistant,
+		Message:          response.Message,
+		MessageType:      msg.MessageType,
+		LinkedToolIDs:    msg.LinkedToolIDs,
+		PromptTemplateID: response.PromptTemplateID,
+	})
+	if err != nil {
+		log.Println("Failed to save AI response:", err)
+		cs.broadcast(msg.SessionID, hub.EventError, streamID, hub.ErrorPayload{Code: hub.ErrorCodeSaveFailed, Message: err.Error()})
+		return
+	}
+
+	systemMsg, err := chatService.ReplyToChatMessage(ctx, aiMsg.ID, branchID, &CreateChatMessageDTO{
+		SessionID:     msg.SessionID,
+		Role:          ChatRoleSystem,
+		Message:       response.SelectedToolID.String(),
+		MessageType:   ChatMessageTypeToolSelection,
+		LinkedToolIDs: msg.LinkedToolIDs,
+	})
+	if err != nil {
+		log.Println("Failed to save tool ID:", err)
+		cs.broadcast(msg.SessionID, hub.EventError, streamID, hub.ErrorPayload{Code: hub.ErrorCodeSaveFailed, Message: err.Error()})
+		return
+	}
+
+	// Log the selection so accept/override outcomes can grow the evaluation dataset
+	var selectionID *uuid.UUID
+	loggedID, err := evaluation.NewEvaluationService(ctx, db).LogSelection(ctx, &evaluation.CreateToolSelectionDTO{
+		SessionID:      msg.SessionID,
+		MessageID:      &systemMsg.ID,
+		Prompt:         msg.Message,
+		SelectedToolID: response.SelectedToolID,
+	})
+	if err != nil {
+		log.Println("Failed to log tool selection:", err)
+	} else {
+		selectionID = &loggedID
+	}
+
+	cs.broadcastMessage(aiMsg, streamID)
+	cs.broadcastMessage(systemMsg, streamID)
+
+	// The user confirms or overrides the suggestion through the assignment API
+	cs.assignments.Suggest(msg.SessionID, response.SelectedToolID, selectionID)
+}
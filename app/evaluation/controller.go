@@ -0,0 +1,152 @@
+package evaluation
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	toolrouter "aigendrug.com/aigendrug-cid-2025-server/tool-router"
+	"github.com/gin-gonic/gin"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type EvaluationController struct {
+	evaluationService EvaluationService
+	router            toolrouter.ToolRouterService
+}
+
+func NewEvaluationController(evaluationService EvaluationService, router toolrouter.ToolRouterService) *EvaluationController {
+	return &EvaluationController{evaluationService: evaluationService, router: router}
+}
+
+func (ec *EvaluationController) GetCases(c *gin.Context) {
+	cases, err := ec.evaluationService.ReadAllCases(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cases)
+}
+
+func (ec *EvaluationController) CreateCase(c *gin.Context) {
+	var dto CreateEvaluationCaseDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	evaluationCase, err := ec.evaluationService.CreateCase(c.Request.Context(), &dto)
+	if err != nil {
+		c.JSON(evaluationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, evaluationCase)
+}
+
+func (ec *EvaluationController) DeleteCase(c *gin.Context) {
+	caseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ec.evaluationService.DeleteCase(c.Request.Context(), caseID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusNoContent, gin.H{})
+}
+
+func (ec *EvaluationController) RunEvaluation(c *gin.Context) {
+	k, err := strconv.Atoi(c.DefaultQuery("k", "3"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := ec.evaluationService.RunEvaluation(c.Request.Context(), ec.router, k)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (ec *EvaluationController) GetSelections(c *gin.Context) {
+	var sessionID *uuid.UUID
+	if raw := c.Query("session_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		sessionID = &parsed
+	}
+
+	selections, err := ec.evaluationService.ReadSelections(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, selections)
+}
+
+func (ec *EvaluationController) AcceptSelection(c *gin.Context) {
+	selectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = ec.evaluationService.AcceptSelection(c.Request.Context(), selectionID)
+	if err != nil {
+		c.JSON(evaluationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"outcome": SelectionOutcomeAccepted})
+}
+
+func (ec *EvaluationController) OverrideSelection(c *gin.Context) {
+	selectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto OverrideToolSelectionDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = ec.evaluationService.OverrideSelection(c.Request.Context(), selectionID, dto.ToolID)
+	if err != nil {
+		c.JSON(evaluationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"outcome": SelectionOutcomeOverridden})
+}
+
+func (ec *EvaluationController) GetSelectionStats(c *gin.Context) {
+	stats, err := ec.evaluationService.ReadSelectionStats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+func evaluationErrorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, ErrSelectionNotFound), errors.Is(err, ErrToolNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrSelectionResolved):
+		return http.StatusConflict
+	case errors.As(err, &validationErrors):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
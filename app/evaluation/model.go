@@ -0,0 +1,90 @@
+package evaluation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CaseSourceManual     = "manual"
+	CaseSourceProduction = "production"
+)
+
+const (
+	SelectionOutcomePending    = "pending"
+	SelectionOutcomeAccepted   = "accepted"
+	SelectionOutcomeOverridden = "overridden"
+)
+
+// PredictionError is used as the predicted tool in the confusion matrix when the router fails.
+const PredictionError = "error"
+
+type EvaluationCase struct {
+	ID             uuid.UUID  `json:"id"`
+	Prompt         string     `json:"prompt"`
+	ExpectedToolID uuid.UUID  `json:"expected_tool_id"`
+	Source         string     `json:"source"`
+	SelectionID    *uuid.UUID `json:"selection_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type CreateEvaluationCaseDTO struct {
+	Prompt         string    `json:"prompt" validate:"required"`
+	ExpectedToolID uuid.UUID `json:"expected_tool_id" validate:"required"`
+}
+
+type EvaluationCaseResult struct {
+	CaseID         uuid.UUID   `json:"case_id"`
+	Prompt         string      `json:"prompt"`
+	ExpectedToolID uuid.UUID   `json:"expected_tool_id"`
+	PredictedTools []uuid.UUID `json:"predicted_tools"`
+	Top1Correct    bool        `json:"top1_correct"`
+	TopKCorrect    bool        `json:"topk_correct"`
+	Error          string      `json:"error,omitempty"`
+}
+
+type EvaluationReport struct {
+	K            int     `json:"k"`
+	Total        int     `json:"total"`
+	Failed       int     `json:"failed"`
+	Top1Correct  int     `json:"top1_correct"`
+	TopKCorrect  int     `json:"topk_correct"`
+	Top1Accuracy float64 `json:"top1_accuracy"`
+	TopKAccuracy float64 `json:"topk_accuracy"`
+	// ConfusionMatrix maps expected tool ID -> predicted top-1 tool ID -> count.
+	ConfusionMatrix map[string]map[string]int `json:"confusion_matrix"`
+	Results         []*EvaluationCaseResult   `json:"results"`
+}
+
+type ToolSelection struct {
+	ID             uuid.UUID  `json:"id"`
+	SessionID      uuid.UUID  `json:"session_id"`
+	MessageID      *uuid.UUID `json:"message_id"`
+	Prompt         string     `json:"prompt"`
+	SelectedToolID uuid.UUID  `json:"selected_tool_id"`
+	Outcome        string     `json:"outcome"`
+	FinalToolID    *uuid.UUID `json:"final_tool_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+}
+
+type CreateToolSelectionDTO struct {
+	SessionID      uuid.UUID
+	MessageID      *uuid.UUID
+	Prompt         string
+	SelectedToolID uuid.UUID
+}
+
+type OverrideToolSelectionDTO struct {
+	ToolID uuid.UUID `json:"tool_id" validate:"required"`
+}
+
+type ToolSelectionStats struct {
+	ToolID         uuid.UUID `json:"tool_id"`
+	Total          int       `json:"total"`
+	Accepted       int       `json:"accepted"`
+	Overridden     int       `json:"overridden"`
+	Pending        int       `json:"pending"`
+	AcceptanceRate float64   `json:"acceptance_rate"`
+}
@@ -0,0 +1,27 @@
+package evaluation
+
+import (
+	"context"
+
+	toolrouter "aigendrug.com/aigendrug-cid-2025-server/tool-router"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupEvaluationRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool) {
+	evaluationService := NewEvaluationService(c, db)
+	evaluationController := NewEvaluationController(evaluationService, toolrouter.NewToolRouterService(c))
+
+	evaluationRoutes := router.Group("/v1/evaluation")
+	{
+		evaluationRoutes.GET("/cases", evaluationController.GetCases)
+		evaluationRoutes.POST("/cases", evaluationController.CreateCase)
+		evaluationRoutes.DELETE("/cases/:id", evaluationController.DeleteCase)
+		evaluationRoutes.POST("/run", evaluationController.RunEvaluation)
+
+		evaluationRoutes.GET("/selections", evaluationController.GetSelections)
+		evaluationRoutes.GET("/selections/stats", evaluationController.GetSelectionStats)
+		evaluationRoutes.POST("/selections/:id/accept", evaluationController.AcceptSelection)
+		evaluationRoutes.POST("/selections/:id/override", evaluationController.OverrideSelection)
+	}
+}
@@ -0,0 +1,345 @@
+package evaluation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/database"
+	toolrouter "aigendrug.com/aigendrug-cid-2025-server/tool-router"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrSelectionNotFound = errors.New("tool selection not found")
+var ErrSelectionResolved = errors.New("tool selection already resolved")
+var ErrToolNotFound = errors.New("tool not found")
+
+type EvaluationService interface {
+	ReadAllCases(rctx context.Context) ([]*EvaluationCase, error)
+	CreateCase(rctx context.Context, dto *CreateEvaluationCaseDTO) (*EvaluationCase, error)
+	DeleteCase(rctx context.Context, id uuid.UUID) error
+	RunEvaluation(rctx context.Context, router toolrouter.ToolRouterService, k int) (*EvaluationReport, error)
+	LogSelection(rctx context.Context, dto *CreateToolSelectionDTO) (uuid.UUID, error)
+	ReadSelections(rctx context.Context, sessionID *uuid.UUID) ([]*ToolSelection, error)
+	AcceptSelection(rctx context.Context, id uuid.UUID) error
+	OverrideSelection(rctx context.Context, id uuid.UUID, toolID uuid.UUID) error
+	ReadSelectionStats(rctx context.Context) ([]*ToolSelectionStats, error)
+}
+
+type evaluationService struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func NewEvaluationService(c context.Context, db *pgxpool.Pool) EvaluationService {
+	return &evaluationService{ctx: c, db: db}
+}
+
+func (s *evaluationService) ReadAllCases(rctx context.Context) ([]*EvaluationCase, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT id, prompt, expected_tool_id, source, selection_id, created_at
+        FROM tool_selection_cases
+        ORDER BY created_at ASC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cases := []*EvaluationCase{}
+	for rows.Next() {
+		var evaluationCase EvaluationCase
+		if err := rows.Scan(
+			&evaluationCase.ID,
+			&evaluationCase.Prompt,
+			&evaluationCase.ExpectedToolID,
+			&evaluationCase.Source,
+			&evaluationCase.SelectionID,
+			&evaluationCase.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		cases = append(cases, &evaluationCase)
+	}
+	return cases, rows.Err()
+}
+
+func (s *evaluationService) CreateCase(rctx context.Context, dto *CreateEvaluationCaseDTO) (*EvaluationCase, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("evaluation case validation failed: %w", err)
+	}
+	if err := checkToolExists(rctx, s.db, dto.ExpectedToolID); err != nil {
+		return nil, err
+	}
+
+	evaluationCase := &EvaluationCase{
+		ID:             uuid.New(),
+		Prompt:         dto.Prompt,
+		ExpectedToolID: dto.ExpectedToolID,
+		Source:         CaseSourceManual,
+		CreatedAt:      time.Now(),
+	}
+
+	_, err := s.db.Exec(rctx, `
+        INSERT INTO tool_selection_cases (id, prompt, expected_tool_id, source, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `, evaluationCase.ID, evaluationCase.Prompt, evaluationCase.ExpectedToolID, evaluationCase.Source, evaluationCase.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return evaluationCase, nil
+}
+
+func (s *evaluationService) DeleteCase(rctx context.Context, id uuid.UUID) error {
+	_, err := s.db.Exec(rctx, "DELETE FROM tool_selection_cases WHERE id = $1", id)
+	return err
+}
+
+// RunEvaluation replays every stored case against router.
+// Top-k accuracy uses ToolRanker when the router implements it, otherwise it equals top-1 accuracy.
+func (s *evaluationService) RunEvaluation(rctx context.Context, router toolrouter.ToolRouterService, k int) (*EvaluationReport, error) {
+	if k < 1 {
+		k = 1
+	}
+
+	cases, err := s.ReadAllCases(rctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &EvaluationReport{
+		K:               k,
+		Total:           len(cases),
+		ConfusionMatrix: map[string]map[string]int{},
+		Results:         make([]*EvaluationCaseResult, 0, len(cases)),
+	}
+
+	for _, evaluationCase := range cases {
+		if err := rctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result := &EvaluationCaseResult{
+			CaseID:         evaluationCase.ID,
+			Prompt:         evaluationCase.Prompt,
+			ExpectedToolID: evaluationCase.ExpectedToolID,
+			PredictedTools: []uuid.UUID{},
+		}
+
+		predicted, err := predictTools(rctx, router, evaluationCase.Prompt, k)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			for _, tool := range predicted {
+				result.PredictedTools = append(result.PredictedTools, tool.ToolID)
+			}
+		}
+
+		expected := evaluationCase.ExpectedToolID.String()
+		if report.ConfusionMatrix[expected] == nil {
+			report.ConfusionMatrix[expected] = map[string]int{}
+		}
+
+		if len(result.PredictedTools) == 0 {
+			report.Failed++
+			report.ConfusionMatrix[expected][PredictionError]++
+		} else {
+			report.ConfusionMatrix[expected][result.PredictedTools[0].String()]++
+			result.Top1Correct = result.PredictedTools[0] == evaluationCase.ExpectedToolID
+			for _, toolID := range result.PredictedTools {
+				if toolID == evaluationCase.ExpectedToolID {
+					result.TopKCorrect = true
+					break
+				}
+			}
+		}
+
+		if result.Top1Correct {
+			report.Top1Correct++
+		}
+		if result.TopKCorrect {
+			report.TopKCorrect++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	if report.Total > 0 {
+		report.Top1Accuracy = float64(report.Top1Correct) / float64(report.Total)
+		report.TopKAccuracy = float64(report.TopKCorrect) / float64(report.Total)
+	}
+
+	return report, nil
+}
+
+func predictTools(rctx context.Context, router toolrouter.ToolRouterService, prompt string, k int) ([]*toolrouter.SelectedTool, error) {
+	if ranker, ok := router.(toolrouter.ToolRanker); ok && k > 1 {
+		tools, err := ranker.RankTools(rctx, prompt, k)
+		if err != nil {
+			return nil, err
+		}
+		if len(tools) > k {
+			tools = tools[:k]
+		}
+		return tools, nil
+	}
+
+	tool, err := router.SelectTool(rctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return []*toolrouter.SelectedTool{tool}, nil
+}
+
+func (s *evaluationService) LogSelection(rctx context.Context, dto *CreateToolSelectionDTO) (uuid.UUID, error) {
+	newUUID := uuid.New()
+	_, err := s.db.Exec(rctx, `
+        INSERT INTO tool_selections (id, session_id, message_id, prompt, selected_tool_id, outcome, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, newUUID, dto.SessionID, dto.MessageID, dto.Prompt, dto.SelectedToolID, SelectionOutcomePending, time.Now())
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return newUUID, nil
+}
+
+func (s *evaluationService) ReadSelections(rctx context.Context, sessionID *uuid.UUID) ([]*ToolSelection, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT id, session_id, message_id, prompt, selected_tool_id, outcome, final_tool_id, created_at, resolved_at
+        FROM tool_selections
+        WHERE $1::uuid IS NULL OR session_id = $1
+        ORDER BY created_at DESC
+    `, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	selections := []*ToolSelection{}
+	for rows.Next() {
+		var selection ToolSelection
+		if err := rows.Scan(
+			&selection.ID,
+			&selection.SessionID,
+			&selection.MessageID,
+			&selection.Prompt,
+			&selection.SelectedToolID,
+			&selection.Outcome,
+			&selection.FinalToolID,
+			&selection.CreatedAt,
+			&selection.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		selections = append(selections, &selection)
+	}
+	return selections, rows.Err()
+}
+
+func (s *evaluationService) AcceptSelection(rctx context.Context, id uuid.UUID) error {
+	return s.resolveSelection(rctx, id, SelectionOutcomeAccepted, nil)
+}
+
+func (s *evaluationService) OverrideSelection(rctx context.Context, id uuid.UUID, toolID uuid.UUID) error {
+	return s.resolveSelection(rctx, id, SelectionOutcomeOverridden, &toolID)
+}
+
+// resolveSelection records the user's outcome and turns the selection into a labelled evaluation case.
+func (s *evaluationService) resolveSelection(rctx context.Context, id uuid.UUID, outcome string, overrideToolID *uuid.UUID) error {
+	return database.WithTx(rctx, s.db, func(tx pgx.Tx) error {
+		return ResolveSelection(rctx, tx, id, outcome, overrideToolID)
+	})
+}
+
+// ResolveSelection is resolveSelection within the caller's transaction, so that other changes made in
+// response to the user, such as the tool assigned to the session, are saved together with the outcome.
+func ResolveSelection(rctx context.Context, tx database.DbExecutor, id uuid.UUID, outcome string, overrideToolID *uuid.UUID) error {
+	var prompt string
+	var selectedToolID uuid.UUID
+	var currentOutcome string
+	err := tx.QueryRow(rctx, `
+        SELECT prompt, selected_tool_id, outcome
+        FROM tool_selections
+        WHERE id = $1
+        FOR UPDATE
+    `, id).Scan(&prompt, &selectedToolID, &currentOutcome)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrSelectionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if currentOutcome != SelectionOutcomePending {
+		return ErrSelectionResolved
+	}
+
+	finalToolID := selectedToolID
+	if overrideToolID != nil {
+		if err := checkToolExists(rctx, tx, *overrideToolID); err != nil {
+			return err
+		}
+		finalToolID = *overrideToolID
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(rctx, `
+        UPDATE tool_selections
+        SET outcome = $2, final_tool_id = $3, resolved_at = $4
+        WHERE id = $1
+    `, id, outcome, finalToolID, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(rctx, `
+        INSERT INTO tool_selection_cases (id, prompt, expected_tool_id, source, selection_id, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, uuid.New(), prompt, finalToolID, CaseSourceProduction, id, now)
+	return err
+}
+
+func checkToolExists(rctx context.Context, db database.DbExecutor, toolID uuid.UUID) error {
+	var exists bool
+	if err := db.QueryRow(rctx, "SELECT EXISTS (SELECT 1 FROM tools WHERE id = $1)", toolID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrToolNotFound
+	}
+	return nil
+}
+
+func (s *evaluationService) ReadSelectionStats(rctx context.Context) ([]*ToolSelectionStats, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT
+            selected_tool_id,
+            COUNT(*),
+            COUNT(*) FILTER (WHERE outcome = $1),
+            COUNT(*) FILTER (WHERE outcome = $2),
+            COUNT(*) FILTER (WHERE outcome = $3)
+        FROM tool_selections
+        GROUP BY selected_tool_id
+        ORDER BY COUNT(*) DESC
+    `, SelectionOutcomeAccepted, SelectionOutcomeOverridden, SelectionOutcomePending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []*ToolSelectionStats{}
+	for rows.Next() {
+		var stat ToolSelectionStats
+		if err := rows.Scan(&stat.ToolID, &stat.Total, &stat.Accepted, &stat.Overridden, &stat.Pending); err != nil {
+			return nil, err
+		}
+		if resolved := stat.Accepted + stat.Overridden; resolved > 0 {
+			stat.AcceptanceRate = float64(stat.Accepted) / float64(resolved)
+		}
+		stats = append(stats, &stat)
+	}
+	return stats, rows.Err()
+}
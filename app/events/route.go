@@ -0,0 +1,19 @@
+package events
+
+import (
+	"context"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/auth"
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupEventRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool, chatHub *hub.Hub, toolHub *hub.Hub, guard *auth.SocketGuard) {
+	eventStream := NewEventStream(db, chatHub, toolHub, guard)
+
+	eventRoutes := router.Group("/v1/events")
+	{
+		eventRoutes.GET("/:sessionID", eventStream.Handler)
+	}
+}
@@ -0,0 +1,279 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/auth"
+	"aigendrug.com/aigendrug-cid-2025-server/app/chat"
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+EventStream serves the chat and tool events of a session as Server-Sent Events, for networks that block
+WebSocket upgrades. It joins the same hubs as the sockets, so both transports receive the same envelopes:
+chat envelopes are sent as "chat" events and tool envelopes as "tool" events.
+
+Every "message" envelope gets an SSE id of the form "<chat seq>-<tool seq>", the position reached in both
+streams. Browsers send it back in the Last-Event-ID header when they reconnect, and everything missed in
+either stream is replayed before live events resume. Messages are sent with the POST /session/messages
+endpoints of the chat and tool APIs.
+*/
+
+const (
+	EventChat = "chat"
+	EventTool = "tool"
+)
+
+type EventStream struct {
+	db      *pgxpool.Pool
+	chatHub *hub.Hub
+	toolHub *hub.Hub
+	guard   *auth.SocketGuard
+}
+
+func NewEventStream(db *pgxpool.Pool, chatHub *hub.Hub, toolHub *hub.Hub, guard *auth.SocketGuard) *EventStream {
+	return &EventStream{db: db, chatHub: chatHub, toolHub: toolHub, guard: guard}
+}
+
+// streamCursor is the position reached in the chat and tool streams of a session
+type streamCursor struct {
+	chatSeq int64
+	toolSeq int64
+}
+
+func (sc streamCursor) String() string {
+	return fmt.Sprintf("%d-%d", sc.chatSeq, sc.toolSeq)
+}
+
+func parseStreamCursor(eventID string) (streamCursor, error) {
+	chatSeq, toolSeq, found := strings.Cut(eventID, "-")
+	if !found {
+		return streamCursor{}, fmt.Errorf("invalid event id %q", eventID)
+	}
+
+	var cursor streamCursor
+	var err error
+	if cursor.chatSeq, err = strconv.ParseInt(chatSeq, 10, 64); err != nil {
+		return streamCursor{}, fmt.Errorf("invalid event id %q: %w", eventID, err)
+	}
+	if cursor.toolSeq, err = strconv.ParseInt(toolSeq, 10, 64); err != nil {
+		return streamCursor{}, fmt.Errorf("invalid event id %q: %w", eventID, err)
+	}
+	return cursor, nil
+}
+
+func (es *EventStream) Handler(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := session.NewSessionService(c.Request.Context(), es.db).ReadSession(c.Request.Context(), sessionID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, session.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	// EventSource cannot set headers, so the ticket comes in the query. It is checked again on automatic
+	// reconnects; once it has expired the client opens a new stream with a fresh ticket and last_event_id.
+	if _, ok := es.guard.Authorize(c, sessionID); !ok {
+		return
+	}
+
+	// EventSource sends Last-Event-ID on reconnects; the query parameter covers the first connection of a new page
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var cursor *streamCursor
+	if lastEventID != "" {
+		parsed, err := parseStreamCursor(lastEventID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		cursor = &parsed
+	}
+
+	// Join before replaying so that nothing broadcast in the meantime is lost
+	chatClient := es.chatHub.Join(sessionID.String())
+	defer es.chatHub.Leave(chatClient)
+	toolClient := es.toolHub.Join(sessionID.String())
+	defer es.toolHub.Leave(toolClient)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Keeps reverse proxies from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	w := &eventWriter{c: c}
+	if cursor != nil {
+		w.position = *cursor
+		if err := es.replay(sessionID, w); err != nil {
+			log.Println("SSE Send Error:", err)
+			return
+		}
+	}
+	replayed := w.position
+
+	ticker := time.NewTicker(es.chatHub.Config().PingInterval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case data, ok := <-chatClient.Messages():
+			if !ok {
+				// Evicted; the client reconnects with its Last-Event-ID
+				return
+			}
+			if !hub.AlreadyReplayed(data, replayed.chatSeq) {
+				err = w.write(EventChat, data)
+			}
+		case data, ok := <-toolClient.Messages():
+			if !ok {
+				return
+			}
+			if !hub.AlreadyReplayed(data, replayed.toolSeq) {
+				err = w.write(EventTool, data)
+			}
+		case <-ticker.C:
+			err = w.comment("ping")
+		}
+		if err != nil {
+			log.Println("SSE Send Error:", err)
+			return
+		}
+	}
+}
+
+// replay sends the chat and tool messages missed since the writer's position, then a "resumed" envelope
+// on each stream. Only write errors are returned; read errors are reported to the client.
+func (es *EventStream) replay(sessionID uuid.UUID, w *eventWriter) error {
+	ctx := context.Background()
+	streams := []struct {
+		event   string
+		lastSeq int64
+		read    func(send func(envelope *hub.Envelope) error, cursor *hub.ResumeCursor) (int64, error)
+	}{
+		{EventChat, w.position.chatSeq, func(send func(envelope *hub.Envelope) error, cursor *hub.ResumeCursor) (int64, error) {
+			messages, err := chat.NewChatService(ctx, es.db).ReadMissedChatMessages(ctx, sessionID, cursor, hub.MaxReplayMessages+1)
+			if err != nil {
+				return 0, err
+			}
+			return hub.SendReplay(send, cursor, messages, func(msg *chat.ChatMessage) int64 { return msg.Seq })
+		}},
+		{EventTool, w.position.toolSeq, func(send func(envelope *hub.Envelope) error, cursor *hub.ResumeCursor) (int64, error) {
+			messages, err := tool.NewToolService(ctx, es.db).ReadMissedToolMessages(ctx, sessionID, cursor, hub.MaxReplayMessages+1)
+			if err != nil {
+				return 0, err
+			}
+			return hub.SendReplay(send, cursor, messages, func(msg *tool.ToolMessage) int64 { return msg.Seq })
+		}},
+	}
+
+	for _, stream := range streams {
+		count := 0
+		var writeErr error
+		send := func(envelope *hub.Envelope) error {
+			if envelope.Type == hub.EventMessage {
+				count++
+			}
+			writeErr = w.writeEnvelope(stream.event, envelope)
+			return writeErr
+		}
+
+		// A seq of 0 means no message of this stream was seen yet, so all of them are replayed
+		lastSeq, err := stream.read(send, &hub.ResumeCursor{LastSeq: stream.lastSeq})
+		if writeErr != nil {
+			return writeErr
+		}
+		if err != nil {
+			log.Println("Replay Error:", err)
+			if err := w.writeEnvelope(stream.event, hub.NewErrorEnvelope("", hub.ErrorCodeReplayFailed, err.Error())); err != nil {
+				return err
+			}
+			continue
+		}
+
+		resumed, err := hub.NewEnvelope(hub.EventResumed, "", hub.ResumedPayload{LastSeq: lastSeq, Count: count})
+		if err != nil {
+			return err
+		}
+		if err := w.writeEnvelope(stream.event, resumed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventWriter writes SSE events and tracks the stream position for their ids
+type eventWriter struct {
+	c        *gin.Context
+	position streamCursor
+}
+
+func (w *eventWriter) writeEnvelope(event string, envelope *hub.Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	return w.write(event, data)
+}
+
+// write sends one envelope. JSON encoded envelopes never contain newlines, so they fit in a single data line.
+func (w *eventWriter) write(event string, data []byte) error {
+	var frame struct {
+		Type string `json:"type"`
+		Seq  int64  `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return err
+	}
+
+	id := ""
+	if frame.Type == hub.EventMessage && frame.Seq > 0 {
+		switch event {
+		case EventChat:
+			w.position.chatSeq = max(w.position.chatSeq, frame.Seq)
+		case EventTool:
+			w.position.toolSeq = max(w.position.toolSeq, frame.Seq)
+		}
+		id = "id: " + w.position.String() + "\n"
+	}
+
+	if _, err := fmt.Fprintf(w.c.Writer, "%sevent: %s\ndata: %s\n\n", id, event, data); err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+// comment keeps idle connections open through proxies; EventSource ignores comment lines
+func (w *eventWriter) comment(text string) error {
+	if _, err := fmt.Fprintf(w.c.Writer, ": %s\n\n", text); err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
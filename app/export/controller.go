@@ -0,0 +1,131 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"github.com/gin-gonic/gin"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type ExportController struct {
+	exportService ExportService
+}
+
+func NewExportController(exportService ExportService) *ExportController {
+	return &ExportController{exportService: exportService}
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// ExportSession downloads the session as a JSON bundle (the default), a Markdown report or an HTML report.
+func (ec *ExportController) ExportSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var query ExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !slices.Contains([]string{"", FormatJSON, FormatMarkdown, FormatHTML}, query.Format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported export format %q", query.Format)})
+		return
+	}
+
+	export, err := ec.exportService.ExportSession(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(exportErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Rendered fully before anything is written, so that template errors still get a proper error response
+	var body bytes.Buffer
+	var contentType, extension string
+	switch query.Format {
+	case FormatMarkdown:
+		contentType, extension = "text/markdown; charset=utf-8", "md"
+		err = RenderMarkdown(&body, export)
+	case FormatHTML:
+		contentType, extension = "text/html; charset=utf-8", "html"
+		err = RenderHTML(&body, export)
+	default:
+		contentType, extension = "application/json; charset=utf-8", "json"
+		err = json.NewEncoder(&body).Encode(export)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.Trim(unsafeFileNameChars.ReplaceAllString(export.Session.Name, "-"), "-")
+	if name == "" {
+		name = export.Session.ID.String()
+	}
+	fileName := fmt.Sprintf("session-%s-%s.%s", name, export.ExportedAt.Format("20060102"), extension)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Data(http.StatusOK, contentType, body.Bytes())
+}
+
+// ImportSession creates a new session from a JSON export bundle.
+func (ec *ExportController) ImportSession(c *gin.Context) {
+	var bundle SessionExport
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	imported, err := ec.exportService.ImportSession(c.Request.Context(), &bundle)
+	if err != nil {
+		c.JSON(exportErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, imported)
+}
+
+// CloneSession copies the session into a new one. The body is optional.
+func (ec *ExportController) CloneSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto CloneSessionDTO
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&dto); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	clone, err := ec.exportService.CloneSession(c.Request.Context(), sessionID, &dto)
+	if err != nil {
+		c.JSON(exportErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, clone)
+}
+
+func exportErrorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidBundle), errors.Is(err, ErrUnknownTools), errors.As(err, &validationErrors):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,450 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/assignment"
+	"aigendrug.com/aigendrug-cid-2025-server/app/chat"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
+	"aigendrug.com/aigendrug-cid-2025-server/database"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrInvalidBundle = errors.New("invalid export bundle")
+var ErrUnknownTools = errors.New("export bundle references tools that are not registered")
+
+var sessionStatuses = []string{session.SessionStatusActive, session.SessionStatusPaused, session.SessionStatusArchived, session.SessionStatusClosed}
+
+var toolStatuses = []string{
+	assignment.ToolStatusSuggested,
+	assignment.ToolStatusConfirmed,
+	assignment.ToolStatusAwaitingInput,
+	assignment.ToolStatusRunning,
+	assignment.ToolStatusCompleted,
+	assignment.ToolStatusFailed,
+}
+
+// sessionCopy is a session with everything that is copied along with it: its branches and messages.
+// Feedback stays with the original messages. Tool selections are not copied either: they are the log of
+// production routing decisions, and copies would be counted again in the selection stats and cases.
+type sessionCopy struct {
+	Session        *session.Session
+	ActiveBranchID *uuid.UUID
+	Branches       []*chat.ChatBranch
+	ChatMessages   []*chat.ChatMessage
+	ToolMessages   []*tool.ToolMessage
+}
+
+// idMap gives every copied row a new ID and translates the references between them.
+type idMap map[uuid.UUID]uuid.UUID
+
+func (m idMap) assign(id uuid.UUID) uuid.UUID {
+	if newID, ok := m[id]; ok {
+		return newID
+	}
+	newID := uuid.New()
+	m[id] = newID
+	return newID
+}
+
+// ref translates a reference to a copied row. References to rows that were not copied are dropped.
+func (m idMap) ref(id *uuid.UUID) *uuid.UUID {
+	if id == nil {
+		return nil
+	}
+	newID, ok := m[*id]
+	if !ok {
+		return nil
+	}
+	return &newID
+}
+
+// ImportSession creates a new session from a JSON export bundle, for instance one exported from another
+// environment. The transcript becomes the main branch and the source session ID is kept as its origin.
+func (s *exportService) ImportSession(rctx context.Context, bundle *SessionExport) (*session.Session, error) {
+	if err := validateBundle(bundle); err != nil {
+		return nil, err
+	}
+
+	copied := bundleCopy(bundle)
+	copied.remap()
+
+	return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*session.Session, error) {
+		if err := checkToolsExist(rctx, tx, copied); err != nil {
+			return nil, err
+		}
+		if err := writeCopy(rctx, tx, copied); err != nil {
+			return nil, err
+		}
+		return copied.Session, nil
+	})
+}
+
+// CloneSession copies a session with all its branches, messages and its tool assignment into a new
+// active session.
+func (s *exportService) CloneSession(rctx context.Context, sessionID uuid.UUID, dto *CloneSessionDTO) (*session.Session, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("clone validation failed: %w", err)
+	}
+
+	return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*session.Session, error) {
+		copied, err := readCopy(rctx, tx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		copied.Session.Status = session.SessionStatusActive
+		if dto.Name != "" {
+			copied.Session.Name = dto.Name
+		} else {
+			copied.Session.Name += " (copy)"
+		}
+		copied.remap()
+
+		if err := writeCopy(rctx, tx, copied); err != nil {
+			return nil, err
+		}
+		return copied.Session, nil
+	})
+}
+
+func validateBundle(bundle *SessionExport) error {
+	if bundle.FormatVersion != FormatVersion {
+		return fmt.Errorf("%w: format version %d is not supported, expected %d", ErrInvalidBundle, bundle.FormatVersion, FormatVersion)
+	}
+	if bundle.Session == nil {
+		return fmt.Errorf("%w: session is missing", ErrInvalidBundle)
+	}
+	if bundle.Session.Name == "" {
+		return fmt.Errorf("%w: session name is missing", ErrInvalidBundle)
+	}
+	if !slices.Contains(sessionStatuses, bundle.Session.Status) {
+		return fmt.Errorf("%w: unknown session status %q", ErrInvalidBundle, bundle.Session.Status)
+	}
+	if bundle.Session.ToolStatus != nil && !slices.Contains(toolStatuses, *bundle.Session.ToolStatus) {
+		return fmt.Errorf("%w: unknown tool status %q", ErrInvalidBundle, *bundle.Session.ToolStatus)
+	}
+	if slices.Contains(bundle.Transcript, nil) || slices.Contains(bundle.ToolRuns, nil) {
+		return fmt.Errorf("%w: empty transcript message or tool run", ErrInvalidBundle)
+	}
+	return nil
+}
+
+// bundleCopy turns the bundle into a copy of its session. Only the active branch is exported, so the
+// transcript is linked into a single main branch, which shares the session's ID.
+func bundleCopy(bundle *SessionExport) *sessionCopy {
+	source := *bundle.Session
+	// Templates are not moved between environments
+	source.TemplateID = nil
+	mainBranchID := source.ID
+	copied := &sessionCopy{
+		Session: &source,
+		Branches: []*chat.ChatBranch{{
+			ID:        mainBranchID,
+			SessionID: mainBranchID,
+			CreatedAt: source.CreatedAt,
+		}},
+	}
+
+	var parentID *uuid.UUID
+	for _, transcriptMessage := range bundle.Transcript {
+		chatMessage := *transcriptMessage
+		chatMessage.ParentID = parentID
+		chatMessage.BranchID = &mainBranchID
+		copied.ChatMessages = append(copied.ChatMessages, &chatMessage)
+		messageID := chatMessage.ID
+		parentID = &messageID
+	}
+
+	seen := map[uuid.UUID]bool{}
+	for _, run := range bundle.ToolRuns {
+		for _, toolMessage := range []*tool.ToolMessage{run.Input, run.Result} {
+			if toolMessage != nil && !seen[toolMessage.ID] {
+				seen[toolMessage.ID] = true
+				copied.ToolMessages = append(copied.ToolMessages, toolMessage)
+			}
+		}
+	}
+	sort.SliceStable(copied.ToolMessages, func(i, j int) bool {
+		return copied.ToolMessages[i].Seq < copied.ToolMessages[j].Seq
+	})
+	return copied
+}
+
+// remap gives the session and every copied row a new ID, points all references at the copies and
+// records the original session as the source. The copy is new, so it starts with fresh session timestamps.
+func (c *sessionCopy) remap() {
+	ids := idMap{}
+	sourceID := c.Session.ID
+
+	// Every row gets its new ID before any reference is translated, whatever order they refer to each other in
+	c.Session.ID = ids.assign(sourceID)
+	for _, branch := range c.Branches {
+		branch.ID = ids.assign(branch.ID)
+	}
+	for _, chatMessage := range c.ChatMessages {
+		chatMessage.ID = ids.assign(chatMessage.ID)
+	}
+	for _, toolMessage := range c.ToolMessages {
+		toolMessage.ID = ids.assign(toolMessage.ID)
+	}
+
+	for _, branch := range c.Branches {
+		branch.SessionID = c.Session.ID
+		branch.ParentBranchID = ids.ref(branch.ParentBranchID)
+		branch.ForkMessageID = ids.ref(branch.ForkMessageID)
+	}
+	for _, chatMessage := range c.ChatMessages {
+		chatMessage.SessionID = c.Session.ID
+		chatMessage.ParentID = ids.ref(chatMessage.ParentID)
+		chatMessage.BranchID = ids.ref(chatMessage.BranchID)
+		if chatMessage.BranchID == nil {
+			mainBranchID := c.Session.ID
+			chatMessage.BranchID = &mainBranchID
+		}
+	}
+	for _, toolMessage := range c.ToolMessages {
+		toolMessage.SessionID = c.Session.ID
+	}
+	c.ActiveBranchID = ids.ref(c.ActiveBranchID)
+
+	// Nothing runs in the copy, so a running tool is back to confirmed
+	if c.Session.ToolStatus != nil && *c.Session.ToolStatus == assignment.ToolStatusRunning {
+		confirmed := assignment.ToolStatusConfirmed
+		c.Session.ToolStatus = &confirmed
+	}
+
+	now := time.Now()
+	c.Session.SourceSessionID = &sourceID
+	c.Session.CreatedAt = now
+	c.Session.UpdatedAt = &now
+	c.Session.StatusChangedAt = &now
+	c.Session.DeletedAt = nil
+}
+
+// readCopy reads everything copied with a session while holding it, so no message is added halfway.
+func readCopy(rctx context.Context, tx pgx.Tx, sessionID uuid.UUID) (*sessionCopy, error) {
+	source, err := session.ShareLockSession(rctx, tx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := &sessionCopy{Session: source}
+	err = tx.QueryRow(rctx, "SELECT active_branch_id FROM sessions WHERE id = $1", sessionID).
+		Scan(&copied.ActiveBranchID)
+	if err != nil {
+		return nil, err
+	}
+
+	if copied.Branches, err = readBranches(rctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	if copied.ChatMessages, err = readChatMessages(rctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	if copied.ToolMessages, err = readToolMessages(rctx, tx, sessionID); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+func readBranches(rctx context.Context, tx pgx.Tx, sessionID uuid.UUID) ([]*chat.ChatBranch, error) {
+	rows, err := tx.Query(rctx, `
+        SELECT id, session_id, parent_branch_id, fork_message_id, created_at
+        FROM chat_branches
+        WHERE session_id = $1
+        ORDER BY created_at, id
+    `, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	branches := []*chat.ChatBranch{}
+	for rows.Next() {
+		var branch chat.ChatBranch
+		if err := rows.Scan(&branch.ID, &branch.SessionID, &branch.ParentBranchID, &branch.ForkMessageID, &branch.CreatedAt); err != nil {
+			return nil, err
+		}
+		branches = append(branches, &branch)
+	}
+	return branches, rows.Err()
+}
+
+func readChatMessages(rctx context.Context, tx pgx.Tx, sessionID uuid.UUID) ([]*chat.ChatMessage, error) {
+	rows, err := tx.Query(rctx, `
+        SELECT id, seq, session_id, role, message, created_at, message_type, linked_tool_ids, parent_id, branch_id, prompt_template_id
+        FROM chat_messages
+        WHERE session_id = $1
+        ORDER BY seq
+    `, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chatMessages := []*chat.ChatMessage{}
+	for rows.Next() {
+		var chatMessage chat.ChatMessage
+		if err := rows.Scan(
+			&chatMessage.ID,
+			&chatMessage.Seq,
+			&chatMessage.SessionID,
+			&chatMessage.Role,
+			&chatMessage.Message,
+			&chatMessage.CreatedAt,
+			&chatMessage.MessageType,
+			&chatMessage.LinkedToolIDs,
+			&chatMessage.ParentID,
+			&chatMessage.BranchID,
+			&chatMessage.PromptTemplateID,
+		); err != nil {
+			return nil, err
+		}
+		chatMessages = append(chatMessages, &chatMessage)
+	}
+	return chatMessages, rows.Err()
+}
+
+func readToolMessages(rctx context.Context, tx pgx.Tx, sessionID uuid.UUID) ([]*tool.ToolMessage, error) {
+	rows, err := tx.Query(rctx, `
+        SELECT id, seq, session_id, tool_id, role, data, created_at
+        FROM tool_messages
+        WHERE session_id = $1
+        ORDER BY seq
+    `, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	toolMessages := []*tool.ToolMessage{}
+	for rows.Next() {
+		var toolMessage tool.ToolMessage
+		var data string
+		if err := rows.Scan(&toolMessage.ID, &toolMessage.Seq, &toolMessage.SessionID, &toolMessage.ToolID, &toolMessage.Role, &data, &toolMessage.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &toolMessage.Data); err != nil {
+			return nil, err
+		}
+		toolMessages = append(toolMessages, &toolMessage)
+	}
+	return toolMessages, rows.Err()
+}
+
+// checkToolsExist refuses imports whose assignment or tool runs use tools unknown here.
+func checkToolsExist(rctx context.Context, tx pgx.Tx, copied *sessionCopy) error {
+	referenced := map[uuid.UUID]bool{}
+	if copied.Session.AssignedToolID != nil {
+		referenced[*copied.Session.AssignedToolID] = true
+	}
+	for _, toolMessage := range copied.ToolMessages {
+		referenced[toolMessage.ToolID] = true
+	}
+	if len(referenced) == 0 {
+		return nil
+	}
+
+	toolIDs := make([]uuid.UUID, 0, len(referenced))
+	for toolID := range referenced {
+		toolIDs = append(toolIDs, toolID)
+	}
+	rows, err := tx.Query(rctx, "SELECT id FROM tools WHERE id = ANY($1)", toolIDs)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var toolID uuid.UUID
+		if err := rows.Scan(&toolID); err != nil {
+			return err
+		}
+		delete(referenced, toolID)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(referenced) > 0 {
+		missing := make([]string, 0, len(referenced))
+		for toolID := range referenced {
+			missing = append(missing, toolID.String())
+		}
+		sort.Strings(missing)
+		return fmt.Errorf("%w: %s", ErrUnknownTools, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// writeCopy saves a remapped copy. Messages are inserted in their original order so their new seq
+// numbers keep it.
+func writeCopy(rctx context.Context, tx pgx.Tx, copied *sessionCopy) error {
+	if err := session.InsertSession(rctx, tx, copied.Session); err != nil {
+		return err
+	}
+
+	for _, branch := range copied.Branches {
+		_, err := tx.Exec(rctx, `
+            INSERT INTO chat_branches (id, session_id, parent_branch_id, fork_message_id, created_at)
+            VALUES ($1, $2, $3, $4, $5)
+        `, branch.ID, branch.SessionID, branch.ParentBranchID, branch.ForkMessageID, branch.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, chatMessage := range copied.ChatMessages {
+		_, err := tx.Exec(rctx, `
+            INSERT INTO chat_messages
+                (id, session_id, role, message, created_at, message_type, linked_tool_ids, parent_id, branch_id, prompt_template_id)
+            VALUES
+                ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        `,
+			chatMessage.ID,
+			chatMessage.SessionID,
+			chatMessage.Role,
+			chatMessage.Message,
+			chatMessage.CreatedAt,
+			chatMessage.MessageType,
+			chatMessage.LinkedToolIDs,
+			chatMessage.ParentID,
+			chatMessage.BranchID,
+			chatMessage.PromptTemplateID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, toolMessage := range copied.ToolMessages {
+		data, err := json.Marshal(toolMessage.Data)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(rctx, `
+            INSERT INTO tool_messages (id, session_id, tool_id, role, data, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6)
+        `, toolMessage.ID, toolMessage.SessionID, toolMessage.ToolID, toolMessage.Role, string(data), toolMessage.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(rctx, `
+        UPDATE sessions SET active_branch_id = $2
+        WHERE id = $1
+    `, copied.Session.ID, copied.ActiveBranchID)
+	return err
+}
@@ -0,0 +1,133 @@
+package export
+
+import (
+	"testing"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/assignment"
+	"aigendrug.com/aigendrug-cid-2025-server/app/chat"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
+	"github.com/google/uuid"
+)
+
+func TestIDMap(t *testing.T) {
+	ids := idMap{}
+	known, unknown := uuid.New(), uuid.New()
+
+	assigned := ids.assign(known)
+	if assigned == known {
+		t.Fatal("assign() kept the original ID")
+	}
+	if again := ids.assign(known); again != assigned {
+		t.Errorf("assign() = %v on the second call, want %v", again, assigned)
+	}
+
+	tests := []struct {
+		name string
+		id   *uuid.UUID
+		want *uuid.UUID
+	}{
+		{name: "copied row", id: &known, want: &assigned},
+		{name: "row that was not copied", id: &unknown, want: nil},
+		{name: "no reference", id: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids.ref(tt.id)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("ref(%v) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionCopyRemap(t *testing.T) {
+	sessionID := uuid.New()
+	running := assignment.ToolStatusRunning
+	// References are separate values, as when scanned from the database
+	ref := func(id uuid.UUID) *uuid.UUID { return &id }
+
+	// The main branch shares the session ID; the fork refers to a message listed after it
+	mainBranch := &chat.ChatBranch{ID: sessionID, SessionID: sessionID}
+	prompt := &chat.ChatMessage{ID: uuid.New(), SessionID: sessionID}
+	forkID := uuid.New()
+	fork := &chat.ChatBranch{ID: forkID, SessionID: sessionID, ParentBranchID: ref(mainBranch.ID), ForkMessageID: ref(prompt.ID)}
+	prompt.BranchID = ref(mainBranch.ID)
+	answer := &chat.ChatMessage{ID: uuid.New(), SessionID: sessionID, ParentID: ref(prompt.ID), BranchID: ref(forkID)}
+	missingParent := uuid.New()
+	orphan := &chat.ChatMessage{ID: uuid.New(), SessionID: sessionID, ParentID: ref(missingParent)}
+	toolMessage := &tool.ToolMessage{ID: uuid.New(), SessionID: sessionID}
+
+	original := map[string]uuid.UUID{
+		"session": sessionID,
+		"fork":    forkID,
+		"prompt":  prompt.ID,
+		"answer":  answer.ID,
+		"orphan":  orphan.ID,
+		"tool":    toolMessage.ID,
+	}
+
+	copied := &sessionCopy{
+		Session:        &session.Session{ID: sessionID, ToolStatus: &running},
+		ActiveBranchID: ref(forkID),
+		Branches:       []*chat.ChatBranch{fork, mainBranch},
+		ChatMessages:   []*chat.ChatMessage{answer, prompt, orphan},
+		ToolMessages:   []*tool.ToolMessage{toolMessage},
+	}
+	copied.remap()
+
+	newSessionID := copied.Session.ID
+	remapped := map[string]uuid.UUID{
+		"session": newSessionID,
+		"fork":    fork.ID,
+		"prompt":  prompt.ID,
+		"answer":  answer.ID,
+		"orphan":  orphan.ID,
+		"tool":    toolMessage.ID,
+	}
+	seen := map[uuid.UUID]bool{}
+	for name, id := range remapped {
+		if id == original[name] {
+			t.Errorf("%s kept its original ID", name)
+		}
+		if seen[id] {
+			t.Errorf("%s shares its new ID with another row", name)
+		}
+		seen[id] = true
+	}
+
+	tests := []struct {
+		name string
+		got  *uuid.UUID
+		want *uuid.UUID
+	}{
+		{name: "main branch keeps the session ID", got: &mainBranch.ID, want: &newSessionID},
+		{name: "fork parent branch", got: fork.ParentBranchID, want: &newSessionID},
+		{name: "fork message", got: fork.ForkMessageID, want: &prompt.ID},
+		{name: "answer parent", got: answer.ParentID, want: &prompt.ID},
+		{name: "answer branch", got: answer.BranchID, want: &fork.ID},
+		{name: "prompt branch", got: prompt.BranchID, want: &newSessionID},
+		{name: "parent that was not copied", got: orphan.ParentID, want: nil},
+		{name: "message without branch joins the main branch", got: orphan.BranchID, want: &newSessionID},
+		{name: "active branch", got: copied.ActiveBranchID, want: &fork.ID},
+		{name: "source session", got: copied.Session.SourceSessionID, want: &sessionID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.got == nil) != (tt.want == nil) || (tt.got != nil && *tt.got != *tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+
+	for _, owner := range []uuid.UUID{fork.SessionID, mainBranch.SessionID, prompt.SessionID, answer.SessionID, orphan.SessionID, toolMessage.SessionID} {
+		if owner != newSessionID {
+			t.Errorf("copied row belongs to session %v, want %v", owner, newSessionID)
+		}
+	}
+	if copied.Session.ToolStatus == nil || *copied.Session.ToolStatus != assignment.ToolStatusConfirmed {
+		t.Errorf("tool status = %v, want %s", copied.Session.ToolStatus, assignment.ToolStatusConfirmed)
+	}
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/chat"
+	"aigendrug.com/aigendrug-cid-2025-server/app/evaluation"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
+	"github.com/google/uuid"
+)
+
+// FormatVersion is bumped whenever the JSON bundle changes incompatibly.
+const FormatVersion = 1
+
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// SessionExport is everything a lab notebook entry needs about a session. It is the JSON bundle
+// and the data behind the Markdown and HTML reports.
+type SessionExport struct {
+	FormatVersion  int                         `json:"format_version"`
+	ExportedAt     time.Time                   `json:"exported_at"`
+	Session        *session.Session            `json:"session"`
+	Transcript     []*chat.ChatMessage         `json:"transcript"`
+	ToolSelections []*evaluation.ToolSelection `json:"tool_selections"`
+	ToolRuns       []*ToolRun                  `json:"tool_runs"`
+	Artifacts      []*Artifact                 `json:"artifacts"`
+	Tools          []*ExportedTool             `json:"tools"`
+}
+
+// ToolRun pairs the input sent to a tool with the result it returned. Either may be missing when
+// the run failed or the messages were created directly.
+type ToolRun struct {
+	ToolID uuid.UUID         `json:"tool_id"`
+	Input  *tool.ToolMessage `json:"input"`
+	Result *tool.ToolMessage `json:"result"`
+}
+
+// Artifact is a structured output of a tool run: any result field besides its "message" text.
+type Artifact struct {
+	ToolMessageID uuid.UUID `json:"tool_message_id"`
+	ToolID        uuid.UUID `json:"tool_id"`
+	Name          string    `json:"name"`
+	Value         any       `json:"value"`
+}
+
+// ExportedTool describes a tool referenced by the session, without its provider credentials and endpoints.
+type ExportedTool struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	Description string    `json:"description"`
+}
+
+type ExportQuery struct {
+	Format string `form:"format"`
+}
+
+// CloneSessionDTO names the clone; by default it is named after the source session.
+type CloneSessionDTO struct {
+	Name string `json:"name" validate:"max=200"`
+}
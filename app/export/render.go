@@ -0,0 +1,118 @@
+package export
+
+import (
+	"embed"
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
+	"github.com/google/uuid"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// Reports only depend on the export, so both templates are parsed once with placeholder functions
+// and cloned with the export's tool names for each render.
+var (
+	markdownReport = texttemplate.Must(texttemplate.New("report.md.tmpl").Funcs(reportFuncs(nil)).ParseFS(templates, "templates/report.md.tmpl"))
+	htmlReport     = htmltemplate.Must(htmltemplate.New("report.html.tmpl").Funcs(reportFuncs(nil)).ParseFS(templates, "templates/report.html.tmpl"))
+)
+
+// RenderMarkdown writes the export as a Markdown report.
+func RenderMarkdown(w io.Writer, export *SessionExport) error {
+	report, err := markdownReport.Clone()
+	if err != nil {
+		return err
+	}
+	return report.Funcs(reportFuncs(export)).Execute(w, export)
+}
+
+// RenderHTML writes the export as a self-contained HTML report with print styles, ready to be saved as PDF.
+func RenderHTML(w io.Writer, export *SessionExport) error {
+	report, err := htmlReport.Clone()
+	if err != nil {
+		return err
+	}
+	return report.Funcs(reportFuncs(export)).Execute(w, export)
+}
+
+func reportFuncs(export *SessionExport) map[string]any {
+	toolNames := map[uuid.UUID]string{}
+	if export != nil {
+		for _, t := range export.Tools {
+			toolNames[t.ID] = t.Name
+		}
+	}
+
+	return map[string]any{
+		// toolName accepts a tool ID as a UUID, a UUID pointer or the text of a tool selection message
+		"toolName": func(id any) string {
+			var toolID uuid.UUID
+			switch value := id.(type) {
+			case uuid.UUID:
+				toolID = value
+			case *uuid.UUID:
+				toolID = *value
+			case string:
+				parsed, err := uuid.Parse(value)
+				if err != nil {
+					return value
+				}
+				toolID = parsed
+			}
+			if name, ok := toolNames[toolID]; ok {
+				return name
+			}
+			return toolID.String()
+		},
+		"date": func(t time.Time) string {
+			return t.Format("2006-01-02 15:04:05")
+		},
+		"role": func(role string) string {
+			if role == "" {
+				return role
+			}
+			return strings.ToUpper(role[:1]) + role[1:]
+		},
+		"json": func(value any) string {
+			data, err := json.MarshalIndent(value, "", "  ")
+			if err != nil {
+				return err.Error()
+			}
+			return string(data)
+		},
+		"compactJSON": func(value any) string {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err.Error()
+			}
+			return string(data)
+		},
+		// cell keeps a value on one line of a Markdown table
+		"cell": func(value string) string {
+			value = strings.ReplaceAll(value, "|", "\\|")
+			return strings.Join(strings.Fields(value), " ")
+		},
+		"join": strings.Join,
+		// metadataText shows strings as they are and other metadata values as JSON
+		"metadataText": func(value any) string {
+			if text, ok := value.(string); ok {
+				return text
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err.Error()
+			}
+			return string(data)
+		},
+		"resultText": func(message *tool.ToolMessage) string {
+			text, _ := message.Data["message"].(string)
+			return text
+		},
+	}
+}
@@ -0,0 +1,20 @@
+package export
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupExportRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool) {
+	exportService := NewExportService(c, db)
+	exportController := NewExportController(exportService)
+
+	exportRoutes := router.Group("/v1/export")
+	{
+		exportRoutes.GET("/:sessionID", exportController.ExportSession)
+		exportRoutes.POST("/import", exportController.ImportSession)
+		exportRoutes.POST("/:sessionID/clone", exportController.CloneSession)
+	}
+}
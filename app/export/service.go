@@ -0,0 +1,197 @@
+package export
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/chat"
+	"aigendrug.com/aigendrug-cid-2025-server/app/evaluation"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// exportPageSize is the page size used to read whole histories
+const exportPageSize = 500
+
+type ExportService interface {
+	ExportSession(rctx context.Context, sessionID uuid.UUID) (*SessionExport, error)
+	ImportSession(rctx context.Context, bundle *SessionExport) (*session.Session, error)
+	CloneSession(rctx context.Context, sessionID uuid.UUID, dto *CloneSessionDTO) (*session.Session, error)
+}
+
+type exportService struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func NewExportService(c context.Context, db *pgxpool.Pool) ExportService {
+	return &exportService{ctx: c, db: db}
+}
+
+// ExportSession collects the session, the transcript of its active branch, its tool selections and
+// its tool runs with their results and artifacts.
+func (s *exportService) ExportSession(rctx context.Context, sessionID uuid.UUID) (*SessionExport, error) {
+	currentSession, err := session.NewSessionService(rctx, s.db).ReadSession(rctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	transcript, err := s.readTranscript(rctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	toolMessages, err := s.readToolMessages(rctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	selections, err := evaluation.NewEvaluationService(rctx, s.db).ReadSelections(rctx, &sessionID)
+	if err != nil {
+		return nil, err
+	}
+	// Selections are listed newest first; reports read in chronological order
+	slices.Reverse(selections)
+
+	toolRuns := pairToolRuns(toolMessages)
+	artifacts := []*Artifact{}
+	for _, run := range toolRuns {
+		artifacts = append(artifacts, runArtifacts(run)...)
+	}
+
+	tools, err := s.referencedTools(rctx, currentSession, selections, toolMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionExport{
+		FormatVersion:  FormatVersion,
+		ExportedAt:     time.Now(),
+		Session:        currentSession,
+		Transcript:     transcript,
+		ToolSelections: selections,
+		ToolRuns:       toolRuns,
+		Artifacts:      artifacts,
+		Tools:          tools,
+	}, nil
+}
+
+// readTranscript reads the whole active branch, page by page from the latest message back.
+func (s *exportService) readTranscript(rctx context.Context, sessionID uuid.UUID) ([]*chat.ChatMessage, error) {
+	chatService := chat.NewChatService(rctx, s.db)
+	messages := []*chat.ChatMessage{}
+	query := &chat.ChatMessageQuery{Limit: exportPageSize}
+	for {
+		page, err := chatService.ReadChatMessages(rctx, sessionID, query)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(page.Messages, messages...)
+		if page.NextCursor == nil {
+			return messages, nil
+		}
+		query.Before = page.NextCursor.String()
+	}
+}
+
+func (s *exportService) readToolMessages(rctx context.Context, sessionID uuid.UUID) ([]*tool.ToolMessage, error) {
+	toolService := tool.NewToolService(rctx, s.db)
+	messages := []*tool.ToolMessage{}
+	query := &tool.ToolMessageQuery{Limit: exportPageSize}
+	for {
+		page, err := toolService.ReadToolMessages(rctx, sessionID, query)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(page.Messages, messages...)
+		if page.NextCursor == nil {
+			return messages, nil
+		}
+		query.Before = page.NextCursor.String()
+	}
+}
+
+// referencedTools describes every tool the session was assigned, suggested or ran.
+func (s *exportService) referencedTools(rctx context.Context, currentSession *session.Session, selections []*evaluation.ToolSelection, toolMessages []*tool.ToolMessage) ([]*ExportedTool, error) {
+	referenced := map[uuid.UUID]bool{}
+	if currentSession.AssignedToolID != nil {
+		referenced[*currentSession.AssignedToolID] = true
+	}
+	for _, selection := range selections {
+		referenced[selection.SelectedToolID] = true
+		if selection.FinalToolID != nil {
+			referenced[*selection.FinalToolID] = true
+		}
+	}
+	for _, message := range toolMessages {
+		referenced[message.ToolID] = true
+	}
+
+	allTools, err := tool.NewToolService(rctx, s.db).ReadAllTools(rctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tools := []*ExportedTool{}
+	for _, t := range allTools {
+		if referenced[t.ID] {
+			tools = append(tools, &ExportedTool{ID: t.ID, Name: t.Name, Version: t.Version, Description: t.Description})
+		}
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools, nil
+}
+
+// pairToolRuns matches every user message to a tool with the next assistant message of the same tool.
+// System messages and unmatched results become runs of their own.
+func pairToolRuns(messages []*tool.ToolMessage) []*ToolRun {
+	runs := []*ToolRun{}
+	open := map[uuid.UUID]*ToolRun{}
+	for _, message := range messages {
+		switch message.Role {
+		case tool.ToolRoleUser:
+			run := &ToolRun{ToolID: message.ToolID, Input: message}
+			runs = append(runs, run)
+			open[message.ToolID] = run
+		case tool.ToolRoleAssistant:
+			if run, ok := open[message.ToolID]; ok {
+				run.Result = message
+				delete(open, message.ToolID)
+				continue
+			}
+			runs = append(runs, &ToolRun{ToolID: message.ToolID, Result: message})
+		default:
+			runs = append(runs, &ToolRun{ToolID: message.ToolID, Result: message})
+		}
+	}
+	return runs
+}
+
+func runArtifacts(run *ToolRun) []*Artifact {
+	if run.Result == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(run.Result.Data))
+	for name := range run.Result.Data {
+		if name != "message" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	artifacts := make([]*Artifact, 0, len(names))
+	for _, name := range names {
+		artifacts = append(artifacts, &Artifact{
+			ToolMessageID: run.Result.ID,
+			ToolID:        run.ToolID,
+			Name:          name,
+			Value:         run.Result.Data[name],
+		})
+	}
+	return artifacts
+}
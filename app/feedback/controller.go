@@ -0,0 +1,78 @@
+package feedback
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type FeedbackController struct {
+	feedbackService FeedbackService
+}
+
+func NewFeedbackController(feedbackService FeedbackService) *FeedbackController {
+	return &FeedbackController{feedbackService: feedbackService}
+}
+
+func (fc *FeedbackController) CreateFeedback(c *gin.Context) {
+	var dto CreateFeedbackDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	feedback, err := fc.feedbackService.CreateFeedback(c.Request.Context(), &dto)
+	if err != nil {
+		c.JSON(feedbackErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, feedback)
+}
+
+func (fc *FeedbackController) GetMessageFeedback(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	feedbacks, err := fc.feedbackService.ReadMessageFeedback(c.Request.Context(), messageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, feedbacks)
+}
+
+func (fc *FeedbackController) GetToolReport(c *gin.Context) {
+	reports, err := fc.feedbackService.ReadToolReport(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+func (fc *FeedbackController) GetCategoryReport(c *gin.Context) {
+	reports, err := fc.feedbackService.ReadCategoryReport(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+func feedbackErrorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, ErrMessageNotFound), errors.Is(err, ErrToolNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &validationErrors):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
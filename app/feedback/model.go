@@ -0,0 +1,53 @@
+package feedback
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FeedbackRatingUp   = "up"
+	FeedbackRatingDown = "down"
+)
+
+const DefaultPromptCategory = "uncategorized"
+
+type Feedback struct {
+	ID              uuid.UUID  `json:"id"`
+	MessageID       uuid.UUID  `json:"message_id"`
+	SessionID       uuid.UUID  `json:"session_id"`
+	Rating          string     `json:"rating"`
+	ToolID          *uuid.UUID `json:"tool_id"`
+	CorrectedToolID *uuid.UUID `json:"corrected_tool_id"`
+	Comment         string     `json:"comment"`
+	PromptCategory  string     `json:"prompt_category"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type CreateFeedbackDTO struct {
+	MessageID       uuid.UUID  `json:"message_id" validate:"required"`
+	Rating          string     `json:"rating" validate:"required,oneof=up down"`
+	CorrectedToolID *uuid.UUID `json:"corrected_tool_id"`
+	Comment         string     `json:"comment"`
+	PromptCategory  string     `json:"prompt_category"`
+}
+
+type ToolFeedbackReport struct {
+	ToolID    uuid.UUID `json:"tool_id"`
+	Total     int       `json:"total"`
+	Up        int       `json:"up"`
+	Down      int       `json:"down"`
+	Overrides int       `json:"overrides"`
+	// CorrectedTo counts how often users replaced this tool with another one.
+	CorrectedTo map[string]int `json:"corrected_to"`
+}
+
+type CategoryFeedbackReport struct {
+	PromptCategory string  `json:"prompt_category"`
+	Total          int     `json:"total"`
+	Up             int     `json:"up"`
+	Down           int     `json:"down"`
+	Overrides      int     `json:"overrides"`
+	ApprovalRate   float64 `json:"approval_rate"`
+}
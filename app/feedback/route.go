@@ -0,0 +1,21 @@
+package feedback
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupFeedbackRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool) {
+	feedbackService := NewFeedbackService(c, db)
+	feedbackController := NewFeedbackController(feedbackService)
+
+	feedbackRoutes := router.Group("/v1/feedback")
+	{
+		feedbackRoutes.POST("", feedbackController.CreateFeedback)
+		feedbackRoutes.GET("/message/:messageID", feedbackController.GetMessageFeedback)
+		feedbackRoutes.GET("/report/tools", feedbackController.GetToolReport)
+		feedbackRoutes.GET("/report/categories", feedbackController.GetCategoryReport)
+	}
+}
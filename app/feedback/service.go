@@ -0,0 +1,226 @@
+package feedback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// toolSelectionMessageType mirrors chat.ChatMessageTypeToolSelection; those messages carry the selected tool ID as text.
+const toolSelectionMessageType = 1
+
+var ErrMessageNotFound = errors.New("chat message not found")
+var ErrToolNotFound = errors.New("tool not found")
+
+type FeedbackService interface {
+	CreateFeedback(rctx context.Context, dto *CreateFeedbackDTO) (*Feedback, error)
+	ReadMessageFeedback(rctx context.Context, messageID uuid.UUID) ([]*Feedback, error)
+	ReadToolReport(rctx context.Context) ([]*ToolFeedbackReport, error)
+	ReadCategoryReport(rctx context.Context) ([]*CategoryFeedbackReport, error)
+}
+
+type feedbackService struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func NewFeedbackService(c context.Context, db *pgxpool.Pool) FeedbackService {
+	return &feedbackService{ctx: c, db: db}
+}
+
+func (s *feedbackService) CreateFeedback(rctx context.Context, dto *CreateFeedbackDTO) (*Feedback, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("feedback validation failed: %w", err)
+	}
+
+	var sessionID uuid.UUID
+	var message string
+	var messageType int
+	var linkedToolIDs []uuid.UUID
+	err := s.db.QueryRow(rctx, `
+        SELECT session_id, message, message_type, linked_tool_ids
+        FROM chat_messages
+        WHERE id = $1
+    `, dto.MessageID).Scan(&sessionID, &message, &messageType, &linkedToolIDs)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if dto.CorrectedToolID != nil {
+		var exists bool
+		if err := s.db.QueryRow(rctx, "SELECT EXISTS (SELECT 1 FROM tools WHERE id = $1)", *dto.CorrectedToolID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrToolNotFound
+		}
+	}
+
+	// Attribute the feedback to the tool the message recommended, if any
+	var toolID *uuid.UUID
+	if messageType == toolSelectionMessageType {
+		if parsed, err := uuid.Parse(message); err == nil {
+			toolID = &parsed
+		}
+	} else if len(linkedToolIDs) > 0 {
+		toolID = &linkedToolIDs[0]
+	}
+
+	category := strings.TrimSpace(dto.PromptCategory)
+	if category == "" {
+		category = DefaultPromptCategory
+	}
+
+	feedback := &Feedback{
+		ID:              uuid.New(),
+		MessageID:       dto.MessageID,
+		SessionID:       sessionID,
+		Rating:          dto.Rating,
+		ToolID:          toolID,
+		CorrectedToolID: dto.CorrectedToolID,
+		Comment:         dto.Comment,
+		PromptCategory:  category,
+		CreatedAt:       time.Now(),
+	}
+
+	_, err = s.db.Exec(rctx, `
+        INSERT INTO chat_message_feedback
+            (id, message_id, session_id, rating, tool_id, corrected_tool_id, comment, prompt_category, created_at)
+        VALUES
+            ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    `,
+		feedback.ID,
+		feedback.MessageID,
+		feedback.SessionID,
+		feedback.Rating,
+		feedback.ToolID,
+		feedback.CorrectedToolID,
+		feedback.Comment,
+		feedback.PromptCategory,
+		feedback.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+func (s *feedbackService) ReadMessageFeedback(rctx context.Context, messageID uuid.UUID) ([]*Feedback, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT id, message_id, session_id, rating, tool_id, corrected_tool_id, comment, prompt_category, created_at
+        FROM chat_message_feedback
+        WHERE message_id = $1
+        ORDER BY created_at ASC
+    `, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feedbacks := []*Feedback{}
+	for rows.Next() {
+		var feedback Feedback
+		if err := rows.Scan(
+			&feedback.ID,
+			&feedback.MessageID,
+			&feedback.SessionID,
+			&feedback.Rating,
+			&feedback.ToolID,
+			&feedback.CorrectedToolID,
+			&feedback.Comment,
+			&feedback.PromptCategory,
+			&feedback.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		feedbacks = append(feedbacks, &feedback)
+	}
+	return feedbacks, rows.Err()
+}
+
+func (s *feedbackService) ReadToolReport(rctx context.Context) ([]*ToolFeedbackReport, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT tool_id, rating, corrected_tool_id, COUNT(*)
+        FROM chat_message_feedback
+        WHERE tool_id IS NOT NULL
+        GROUP BY tool_id, rating, corrected_tool_id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*ToolFeedbackReport{}
+	byTool := map[uuid.UUID]*ToolFeedbackReport{}
+	for rows.Next() {
+		var toolID uuid.UUID
+		var rating string
+		var correctedToolID *uuid.UUID
+		var count int
+		if err := rows.Scan(&toolID, &rating, &correctedToolID, &count); err != nil {
+			return nil, err
+		}
+
+		report, exists := byTool[toolID]
+		if !exists {
+			report = &ToolFeedbackReport{ToolID: toolID, CorrectedTo: map[string]int{}}
+			byTool[toolID] = report
+			reports = append(reports, report)
+		}
+
+		report.Total += count
+		switch rating {
+		case FeedbackRatingUp:
+			report.Up += count
+		case FeedbackRatingDown:
+			report.Down += count
+		}
+		if correctedToolID != nil && *correctedToolID != toolID {
+			report.Overrides += count
+			report.CorrectedTo[correctedToolID.String()] += count
+		}
+	}
+	return reports, rows.Err()
+}
+
+func (s *feedbackService) ReadCategoryReport(rctx context.Context) ([]*CategoryFeedbackReport, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT
+            prompt_category,
+            COUNT(*),
+            COUNT(*) FILTER (WHERE rating = $1),
+            COUNT(*) FILTER (WHERE rating = $2),
+            COUNT(*) FILTER (WHERE corrected_tool_id IS NOT NULL AND corrected_tool_id IS DISTINCT FROM tool_id)
+        FROM chat_message_feedback
+        GROUP BY prompt_category
+        ORDER BY COUNT(*) DESC
+    `, FeedbackRatingUp, FeedbackRatingDown)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*CategoryFeedbackReport{}
+	for rows.Next() {
+		var report CategoryFeedbackReport
+		if err := rows.Scan(&report.PromptCategory, &report.Total, &report.Up, &report.Down, &report.Overrides); err != nil {
+			return nil, err
+		}
+		if report.Total > 0 {
+			report.ApprovalRate = float64(report.Up) / float64(report.Total)
+		}
+		reports = append(reports, &report)
+	}
+	return reports, rows.Err()
+}
@@ -0,0 +1,107 @@
+package hub
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Broker carries hub messages between server instances.
+// Implementations must not hand a message back to the instance that published it.
+type Broker interface {
+	Publish(ctx context.Context, topic string, sessionID string, data []byte) error
+	// Subscribe delivers messages published by other instances until ctx is cancelled or the subscription fails.
+	Subscribe(ctx context.Context, handler func(topic string, sessionID string, data []byte)) error
+}
+
+const (
+	// relayQueueSize is how many broadcasts may wait for the broker before new ones are dropped.
+	relayQueueSize = 1024
+	// relayPublishTimeout bounds every publish so a stalled broker cannot hold up the queue.
+	relayPublishTimeout = 5 * time.Second
+)
+
+type relayMessage struct {
+	topic     string
+	sessionID string
+	data      []byte
+}
+
+// Relay connects the hubs of this instance to a Broker shared by all instances.
+// Each hub is registered under a topic, and messages broadcast on one instance reach the same hub on every other.
+// Broadcasts are queued and published in order by Run, so streaming many deltas never waits on the broker.
+type Relay struct {
+	broker Broker
+	queue  chan relayMessage
+	mu     sync.RWMutex
+	hubs   map[string]*Hub
+}
+
+func NewRelay(broker Broker) *Relay {
+	return &Relay{broker: broker, queue: make(chan relayMessage, relayQueueSize), hubs: make(map[string]*Hub)}
+}
+
+func (r *Relay) Register(topic string, h *Hub) {
+	r.mu.Lock()
+	r.hubs[topic] = h
+	r.mu.Unlock()
+
+	h.setPublisher(func(sessionID string, data []byte) {
+		select {
+		case r.queue <- relayMessage{topic: topic, sessionID: sessionID, data: data}:
+		default:
+			log.Println("Broker Publish Error: queue full, dropping message for session", sessionID)
+		}
+	})
+}
+
+// Run publishes queued broadcasts and subscribes to the broker until ctx is cancelled, resubscribing after failures.
+func (r *Relay) Run(ctx context.Context) {
+	go r.publishQueued(ctx)
+
+	backoff := time.Second
+
+	for {
+		err := r.broker.Subscribe(ctx, r.dispatch)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Println("Broker Subscribe Error:", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (r *Relay) publishQueued(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-r.queue:
+			publishCtx, cancel := context.WithTimeout(ctx, relayPublishTimeout)
+			err := r.broker.Publish(publishCtx, message.topic, message.sessionID, message.data)
+			cancel()
+			if err != nil {
+				log.Println("Broker Publish Error:", err)
+			}
+		}
+	}
+}
+
+func (r *Relay) dispatch(topic string, sessionID string, data []byte) {
+	r.mu.RLock()
+	h, exists := r.hubs[topic]
+	r.mu.RUnlock()
+
+	if exists {
+		h.deliver(sessionID, data)
+	}
+}
@@ -0,0 +1,64 @@
+package hub
+
+import (
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	CloseReasonSlowConsumer = "slow consumer"
+	CloseReasonIdleTimeout  = "idle timeout"
+	CloseReasonTooLarge     = "message too large"
+	CloseReasonRestarting   = "server restarting"
+)
+
+// Client is one subscriber of a session. Its queue is closed by the session goroutine when the client
+// leaves or is evicted, which tells the writer to stop.
+type Client struct {
+	hub       *Hub
+	session   *sessionHub
+	sessionID string
+	send      chan []byte
+	leaveOnce sync.Once
+
+	closeMu     sync.Mutex
+	closeCode   int
+	closeReason string
+}
+
+func (c *Client) SessionID() string {
+	return c.sessionID
+}
+
+// Messages returns the client's queue of JSON encoded messages.
+func (c *Client) Messages() <-chan []byte {
+	return c.send
+}
+
+// CloseStatus reports why the queue was closed. It is only meaningful once Messages is closed.
+func (c *Client) CloseStatus() (int, string) {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+
+	if c.closeCode == 0 {
+		return websocket.CloseNormalClosure, ""
+	}
+	return c.closeCode, c.closeReason
+}
+
+// setCloseStatus keeps the first close status, which is the actual cause.
+func (c *Client) setCloseStatus(code int, reason string) {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+
+	if c.closeCode == 0 {
+		c.closeCode = code
+		c.closeReason = reason
+	}
+}
+
+func (c *Client) evict(reason string) {
+	c.setCloseStatus(websocket.CloseTryAgainLater, reason)
+	close(c.send)
+}
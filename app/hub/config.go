@@ -0,0 +1,61 @@
+package hub
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+type Config struct {
+	// PingInterval is how often the server pings each connection. It must be shorter than IdleTimeout.
+	PingInterval time.Duration
+	// IdleTimeout closes connections that sent neither a frame nor a pong for this long.
+	IdleTimeout time.Duration
+	// WriteTimeout bounds every single write to a connection.
+	WriteTimeout time.Duration
+	// MaxMessageSize is the largest frame in bytes a client may send.
+	MaxMessageSize int64
+	// SendBufferSize is the number of queued messages after which a client is evicted as a slow consumer.
+	SendBufferSize int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PingInterval:   30 * time.Second,
+		IdleTimeout:    60 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxMessageSize: 64 * 1024,
+		SendBufferSize: 64,
+	}
+}
+
+// ConfigFromEnv reads WS_PING_INTERVAL, WS_IDLE_TIMEOUT, WS_WRITE_TIMEOUT, WS_MAX_MESSAGE_SIZE and
+// WS_SEND_BUFFER_SIZE, keeping the default for any value that is missing or invalid.
+func ConfigFromEnv() Config {
+	config := DefaultConfig()
+
+	if d, err := time.ParseDuration(os.Getenv("WS_PING_INTERVAL")); err == nil && d > 0 {
+		config.PingInterval = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("WS_IDLE_TIMEOUT")); err == nil && d > 0 {
+		config.IdleTimeout = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("WS_WRITE_TIMEOUT")); err == nil && d > 0 {
+		config.WriteTimeout = d
+	}
+	if n, err := strconv.ParseInt(os.Getenv("WS_MAX_MESSAGE_SIZE"), 10, 64); err == nil && n > 0 {
+		config.MaxMessageSize = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("WS_SEND_BUFFER_SIZE")); err == nil && n > 0 {
+		config.SendBufferSize = n
+	}
+
+	if config.PingInterval >= config.IdleTimeout {
+		config.PingInterval = config.IdleTimeout * 9 / 10
+	}
+	return config
+}
+
+func (h *Hub) Config() Config {
+	return h.config
+}
@@ -0,0 +1,121 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ProtocolVersion is the version of the envelope protocol spoken on every socket.
+const ProtocolVersion = 1
+
+const (
+	// A persisted chat or tool message
+	EventMessage = "message"
+	// A chunk of an assistant message that is still being generated; correlation_id identifies the stream
+	EventDelta = "delta"
+	// Confirms that the client frame with id == correlation_id was processed
+	EventAck = "ack"
+	// Reports a failure; correlation_id points at the client frame or stream that failed, if any
+	EventError = "error"
+	// Progress of a tool run or tool-assisted answer
+	EventToolJobProgress = "tool-job-progress"
+	// The tool assigned to a session or its tool status changed
+	EventToolStatus = "tool-status"
+	// Participants joining, leaving or changing state in a session
+	EventPresence = "presence"
+	// Ends the replay of missed messages after a reconnect; live messages follow
+	EventResumed = "resumed"
+	// The generated title and summary of a session were refreshed
+	EventSessionSummary = "session-summary"
+)
+
+const (
+	ErrorCodeInvalidFrame = "invalid_frame"
+	ErrorCodeUnsupported  = "unsupported_version"
+	ErrorCodeUnknownType  = "unknown_type"
+	ErrorCodeSaveFailed   = "save_failed"
+	ErrorCodeAIFailed     = "ai_failed"
+	// The answer was cut short by a server shutdown and recorded as an interrupted operation
+	ErrorCodeInterrupted    = "interrupted"
+	ErrorCodeInvalidPayload = "invalid_payload"
+	// The payload decoded but was refused, e.g. a role or session the client may not write to
+	ErrorCodeValidationFailed = "validation_failed"
+	// The session is archived or closed and takes no new messages
+	ErrorCodeSessionReadOnly = "session_read_only"
+	ErrorCodeReplayFailed    = "replay_failed"
+	// More messages were missed than can be replayed; the client should reload the history over REST
+	ErrorCodeReplayTruncated = "replay_truncated"
+)
+
+// Envelope wraps every frame sent or received on a socket.
+// Client frames should carry their own id so that acks and errors can refer back to them.
+// Server "message" frames carry the seq of the persisted message, see ResumeCursor.
+type Envelope struct {
+	Version       int             `json:"v"`
+	Type          string          `json:"type"`
+	ID            string          `json:"id"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Seq           int64           `json:"seq,omitempty"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+}
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type AckPayload struct {
+	// ID of the resource created by the acknowledged frame, if any
+	ID string `json:"id,omitempty"`
+}
+
+type DeltaPayload struct {
+	Content string `json:"content"`
+}
+
+func NewEnvelope(eventType string, correlationID string, payload any) (*Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		Version:       ProtocolVersion,
+		Type:          eventType,
+		ID:            uuid.NewString(),
+		CorrelationID: correlationID,
+		Payload:       data,
+	}, nil
+}
+
+func NewErrorEnvelope(correlationID string, code string, message string) *Envelope {
+	envelope, _ := NewEnvelope(EventError, correlationID, ErrorPayload{Code: code, Message: message})
+	return envelope
+}
+
+// ParseEnvelope decodes a client frame and checks its version.
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrorCodeInvalidFrame, err)
+	}
+	if envelope.Version != ProtocolVersion {
+		return &envelope, fmt.Errorf("%s: expected protocol version %d", ErrorCodeUnsupported, ProtocolVersion)
+	}
+	if envelope.Type == "" {
+		return &envelope, fmt.Errorf("%s: missing type", ErrorCodeInvalidFrame)
+	}
+	return &envelope, nil
+}
+
+// DecodePayload unmarshals the envelope payload into v.
+func (e *Envelope) DecodePayload(v any) error {
+	if len(e.Payload) == 0 {
+		return fmt.Errorf("%s: missing payload", ErrorCodeInvalidPayload)
+	}
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("%s: %w", ErrorCodeInvalidPayload, err)
+	}
+	return nil
+}
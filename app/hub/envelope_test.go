@@ -0,0 +1,79 @@
+package hub
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEnvelope(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantType string
+		wantCode string
+	}{
+		{
+			name:     "valid frame",
+			data:     `{"v":1,"type":"message","id":"frame-1","payload":{"message":"hi"}}`,
+			wantType: EventMessage,
+		},
+		{
+			name:     "frame without payload",
+			data:     `{"v":1,"type":"typing","id":"frame-2"}`,
+			wantType: "typing",
+		},
+		{name: "not json", data: `hello`, wantCode: ErrorCodeInvalidFrame},
+		{name: "missing version", data: `{"type":"message"}`, wantCode: ErrorCodeUnsupported},
+		{name: "other version", data: `{"v":2,"type":"message"}`, wantCode: ErrorCodeUnsupported},
+		{name: "missing type", data: `{"v":1,"id":"frame-3"}`, wantCode: ErrorCodeInvalidFrame},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope, err := ParseEnvelope([]byte(tt.data))
+			if tt.wantCode != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantCode+":") {
+					t.Fatalf("ParseEnvelope() error = %v, want code %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseEnvelope() error = %v", err)
+			}
+			if envelope.Type != tt.wantType {
+				t.Errorf("ParseEnvelope() type = %q, want %q", envelope.Type, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid payload", payload: `{"content":"chunk"}`, want: "chunk"},
+		{name: "missing payload", payload: ``, wantErr: true},
+		{name: "wrong shape", payload: `{"content":1}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope := &Envelope{Version: ProtocolVersion, Type: EventDelta, Payload: []byte(tt.payload)}
+
+			var delta DeltaPayload
+			err := envelope.DecodePayload(&delta)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodePayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), ErrorCodeInvalidPayload+":") {
+				t.Errorf("DecodePayload() error = %v, want code %s", err, ErrorCodeInvalidPayload)
+			}
+			if delta.Content != tt.want {
+				t.Errorf("DecodePayload() content = %q, want %q", delta.Content, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Hub fans messages out to the clients of each session.
+
+Every session with at least one client gets its own goroutine, so a busy or slow session never blocks another one.
+Every client gets a buffered send queue drained by its own writer (see ServeWebSocket). When a client's queue is
+full, the client is evicted instead of stalling the rest of its session.
+*/
+
+const defaultBroadcastBufferSize = 256
+
+type Hub struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionHub
+	config   Config
+
+	// publish forwards locally broadcast messages to other instances, see Relay
+	publish func(sessionID string, data []byte)
+
+	// Set by Shutdown; clients joining afterwards are closed right away
+	shuttingDown bool
+	closeCode    int
+	closeReason  string
+
+	joined  atomic.Uint64
+	evicted atomic.Uint64
+	// Number of ServeWebSocket calls that have not returned yet
+	serving atomic.Int64
+}
+
+type sessionHub struct {
+	hub        *Hub
+	id         string
+	members    int // Clients joined and not yet left, guarded by Hub.mu
+	register   chan *Client
+	unregister chan *Client
+	broadcast  chan []byte
+	direct     chan directMessage
+	closeAll   chan struct{}
+	quit       chan struct{}
+}
+
+type directMessage struct {
+	client *Client
+	data   []byte
+}
+
+func NewHub(config Config) *Hub {
+	return &Hub{
+		sessions: make(map[string]*sessionHub),
+		config:   config,
+	}
+}
+
+// Join registers a new client in the session and starts the session goroutine if needed.
+// Every client returned by Join must be released with Leave.
+func (h *Hub) Join(sessionID string) *Client {
+	client := &Client{
+		hub:       h,
+		sessionID: sessionID,
+		send:      make(chan []byte, h.config.SendBufferSize),
+	}
+	h.joined.Add(1)
+
+	h.mu.Lock()
+	s, exists := h.sessions[sessionID]
+	if !exists {
+		s = &sessionHub{
+			hub:        h,
+			id:         sessionID,
+			register:   make(chan *Client),
+			unregister: make(chan *Client),
+			broadcast:  make(chan []byte, defaultBroadcastBufferSize),
+			direct:     make(chan directMessage, defaultBroadcastBufferSize),
+			closeAll:   make(chan struct{}, 1),
+			quit:       make(chan struct{}),
+		}
+		h.sessions[sessionID] = s
+		go s.run()
+	}
+	s.members++
+	client.session = s
+	shuttingDown := h.shuttingDown
+	h.mu.Unlock()
+
+	// quit cannot be closed while this client is counted as a member
+	s.register <- client
+	if shuttingDown {
+		s.requestCloseAll()
+	}
+	return client
+}
+
+// Shutdown closes the queue of every client with code and reason, so that writers send a close frame, and
+// does the same to clients joining later. It waits until every ServeWebSocket call returned or ctx is done.
+func (h *Hub) Shutdown(ctx context.Context, code int, reason string) error {
+	h.mu.Lock()
+	h.shuttingDown = true
+	h.closeCode, h.closeReason = code, reason
+	sessions := make([]*sessionHub, 0, len(h.sessions))
+	for _, s := range h.sessions {
+		sessions = append(sessions, s)
+	}
+	h.mu.Unlock()
+
+	for _, s := range sessions {
+		s.requestCloseAll()
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for h.serving.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Leave unregisters the client. It is safe to call more than once.
+func (h *Hub) Leave(client *Client) {
+	h.LeaveWithStatus(client, 0, "")
+}
+
+// LeaveWithStatus unregisters the client and records the close code and reason its writer should send.
+func (h *Hub) LeaveWithStatus(client *Client, code int, reason string) {
+	client.leaveOnce.Do(func() {
+		if code != 0 {
+			client.setCloseStatus(code, reason)
+		}
+
+		s := client.session
+
+		h.mu.Lock()
+		s.members--
+		last := s.members == 0
+		if last {
+			delete(h.sessions, s.id)
+		}
+		h.mu.Unlock()
+
+		if last {
+			// The session goroutine closes the queues of all remaining clients on quit
+			close(s.quit)
+			return
+		}
+
+		select {
+		case s.unregister <- client:
+		case <-s.quit:
+		}
+	})
+}
+
+// Broadcast marshals payload to JSON once and queues it for every client in the session,
+// on this instance and, when a Relay is registered, on every other instance.
+func (h *Hub) Broadcast(sessionID string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	h.deliver(sessionID, data)
+
+	h.mu.Lock()
+	publish := h.publish
+	h.mu.Unlock()
+	if publish != nil {
+		publish(sessionID, data)
+	}
+	return nil
+}
+
+// deliver queues data for the session's clients on this instance. Sessions without clients are skipped.
+func (h *Hub) deliver(sessionID string, data []byte) {
+	h.mu.Lock()
+	s, exists := h.sessions[sessionID]
+	h.mu.Unlock()
+	if !exists {
+		return
+	}
+
+	select {
+	case s.broadcast <- data:
+	case <-s.quit:
+	}
+}
+
+// SendTo queues payload for a single client of this instance, e.g. an ack or error for a frame it sent.
+func (h *Hub) SendTo(client *Client, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	s := client.session
+	select {
+	case s.direct <- directMessage{client: client, data: data}:
+	case <-s.quit:
+	}
+	return nil
+}
+
+func (h *Hub) setPublisher(publish func(sessionID string, data []byte)) {
+	h.mu.Lock()
+	h.publish = publish
+	h.mu.Unlock()
+}
+
+func (s *sessionHub) requestCloseAll() {
+	select {
+	case s.closeAll <- struct{}{}:
+	default:
+		// A close is already pending and covers every current client
+	}
+}
+
+func (s *sessionHub) run() {
+	clients := make(map[*Client]bool)
+
+	for {
+		select {
+		case client := <-s.register:
+			clients[client] = true
+		case client := <-s.unregister:
+			if clients[client] {
+				delete(clients, client)
+				close(client.send)
+			}
+		case data := <-s.broadcast:
+			for client := range clients {
+				s.enqueue(clients, client, data)
+			}
+		case message := <-s.direct:
+			if clients[message.client] {
+				s.enqueue(clients, message.client, message.data)
+			}
+		case <-s.closeAll:
+			s.hub.mu.Lock()
+			code, reason := s.hub.closeCode, s.hub.closeReason
+			s.hub.mu.Unlock()
+
+			for client := range clients {
+				delete(clients, client)
+				client.setCloseStatus(code, reason)
+				close(client.send)
+			}
+		case <-s.quit:
+			for client := range clients {
+				close(client.send)
+			}
+			return
+		}
+	}
+}
+
+func (s *sessionHub) enqueue(clients map[*Client]bool, client *Client, data []byte) {
+	select {
+	case client.send <- data:
+	default:
+		// The client's writer can't keep up, drop it rather than block the session
+		delete(clients, client)
+		client.evict(CloseReasonSlowConsumer)
+		s.hub.evicted.Add(1)
+	}
+}
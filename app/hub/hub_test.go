@@ -0,0 +1,173 @@
+package hub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestHub(sendBufferSize int) *Hub {
+	config := DefaultConfig()
+	config.SendBufferSize = sendBufferSize
+	return NewHub(config)
+}
+
+// receive returns the next message queued for client, failing the test if none arrives in time.
+func receive(t *testing.T, client *Client) string {
+	t.Helper()
+
+	select {
+	case data, ok := <-client.Messages():
+		if !ok {
+			t.Fatal("queue closed, want a message")
+		}
+		return string(data)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+		return ""
+	}
+}
+
+// waitClosed drains client's queue until it is closed and returns the number of messages drained.
+func waitClosed(t *testing.T, client *Client) int {
+	t.Helper()
+
+	drained := 0
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-client.Messages():
+			if !ok {
+				return drained
+			}
+			drained++
+		case <-timeout:
+			t.Fatal("timed out waiting for the queue to close")
+		}
+	}
+}
+
+func assertCloseStatus(t *testing.T, client *Client, wantCode int, wantReason string) {
+	t.Helper()
+
+	code, reason := client.CloseStatus()
+	if code != wantCode || reason != wantReason {
+		t.Errorf("CloseStatus() = (%d, %q), want (%d, %q)", code, reason, wantCode, wantReason)
+	}
+}
+
+func TestHubEvictsSlowConsumer(t *testing.T) {
+	h := newTestHub(1)
+	slow := h.Join("session")
+	fast := h.Join("session")
+	defer h.Leave(fast)
+	defer h.Leave(slow)
+
+	// The first message fills the slow client's queue and the second one overflows it
+	for _, message := range []string{"first", "second"} {
+		if err := h.Broadcast("session", message); err != nil {
+			t.Fatalf("Broadcast() error = %v", err)
+		}
+		if got, want := receive(t, fast), `"`+message+`"`; got != want {
+			t.Fatalf("fast client got %s, want %s", got, want)
+		}
+	}
+
+	if drained := waitClosed(t, slow); drained != 1 {
+		t.Errorf("slow client drained %d messages before eviction, want 1", drained)
+	}
+	assertCloseStatus(t, slow, websocket.CloseTryAgainLater, CloseReasonSlowConsumer)
+	if evicted := h.Stats().Evicted; evicted != 1 {
+		t.Errorf("Stats().Evicted = %d, want 1", evicted)
+	}
+
+	if err := h.Broadcast("session", "third"); err != nil {
+		t.Fatalf("Broadcast() error = %v", err)
+	}
+	if got := receive(t, fast); got != `"third"` {
+		t.Errorf("fast client got %s after the eviction, want %q", got, `"third"`)
+	}
+}
+
+func TestHubLeaveAfterEviction(t *testing.T) {
+	tests := []struct {
+		name   string
+		others int
+	}{
+		{name: "other clients remain", others: 1},
+		{name: "evicted client is the last member", others: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHub(1)
+			evicted := h.Join("session")
+			others := make([]*Client, tt.others)
+			for i := range others {
+				others[i] = h.Join("session")
+			}
+
+			for _, message := range []string{"first", "second"} {
+				if err := h.Broadcast("session", message); err != nil {
+					t.Fatalf("Broadcast() error = %v", err)
+				}
+				for _, other := range others {
+					receive(t, other)
+				}
+			}
+			// Without other clients nothing else tells when the session goroutine handled both messages
+			deadline := time.Now().Add(time.Second)
+			for h.Stats().Evicted == 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("timed out waiting for the eviction")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			waitClosed(t, evicted)
+
+			// Closing the evicted queue a second time would panic
+			h.Leave(evicted)
+			h.LeaveWithStatus(evicted, websocket.CloseGoingAway, "")
+			assertCloseStatus(t, evicted, websocket.CloseTryAgainLater, CloseReasonSlowConsumer)
+
+			for _, other := range others {
+				h.Leave(other)
+				waitClosed(t, other)
+			}
+			if sessions := h.Stats().Sessions; sessions != 0 {
+				t.Errorf("Stats().Sessions = %d after every client left, want 0", sessions)
+			}
+		})
+	}
+}
+
+func TestHubShutdownClosesCurrentAndLaterClients(t *testing.T) {
+	h := newTestHub(4)
+	current := []*Client{h.Join("first"), h.Join("first"), h.Join("second")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx, websocket.CloseServiceRestart, CloseReasonRestarting); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	for _, client := range current {
+		waitClosed(t, client)
+		assertCloseStatus(t, client, websocket.CloseServiceRestart, CloseReasonRestarting)
+	}
+
+	later := []*Client{h.Join("first"), h.Join("third")}
+	for _, client := range later {
+		waitClosed(t, client)
+		assertCloseStatus(t, client, websocket.CloseServiceRestart, CloseReasonRestarting)
+	}
+
+	for _, client := range append(current, later...) {
+		h.Leave(client)
+	}
+	if sessions := h.Stats().Sessions; sessions != 0 {
+		t.Errorf("Stats().Sessions = %d after every client left, want 0", sessions)
+	}
+}
@@ -0,0 +1,135 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	postgresBrokerChannel = "hub_events"
+	// NOTIFY payloads are limited to 8000 bytes; larger messages are stored in hub_event_payloads
+	maxNotifyPayloadSize = 7500
+	payloadRetention     = 5 * time.Minute
+)
+
+type notification struct {
+	Origin    string          `json:"o"`
+	Topic     string          `json:"t"`
+	SessionID string          `json:"s"`
+	Data      json.RawMessage `json:"d,omitempty"`
+	PayloadID *uuid.UUID      `json:"p,omitempty"`
+}
+
+// PostgresBroker is a Broker on top of Postgres LISTEN/NOTIFY using the application's pool.
+type PostgresBroker struct {
+	db         *pgxpool.Pool
+	instanceID string
+}
+
+func NewPostgresBroker(db *pgxpool.Pool) *PostgresBroker {
+	return &PostgresBroker{db: db, instanceID: uuid.NewString()}
+}
+
+func (b *PostgresBroker) Publish(ctx context.Context, topic string, sessionID string, data []byte) error {
+	message := notification{
+		Origin:    b.instanceID,
+		Topic:     topic,
+		SessionID: sessionID,
+		Data:      data,
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > maxNotifyPayloadSize {
+		payloadID := uuid.New()
+		_, err := b.db.Exec(ctx, `
+            INSERT INTO hub_event_payloads (id, payload, created_at)
+            VALUES ($1, $2, $3)
+        `, payloadID, string(data), time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to store hub event payload: %w", err)
+		}
+
+		message.Data = nil
+		message.PayloadID = &payloadID
+		if payload, err = json.Marshal(message); err != nil {
+			return err
+		}
+	}
+
+	_, err = b.db.Exec(ctx, "SELECT pg_notify($1, $2)", postgresBrokerChannel, string(payload))
+	return err
+}
+
+// Subscribe takes one connection out of the pool for LISTEN while the subscription is active.
+func (b *PostgresBroker) Subscribe(ctx context.Context, handler func(topic string, sessionID string, data []byte)) error {
+	pooled, err := b.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A listening connection must not go back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+postgresBrokerChannel); err != nil {
+		return err
+	}
+
+	cleanupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go b.deleteExpiredPayloads(cleanupCtx)
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var message notification
+		if err := json.Unmarshal([]byte(n.Payload), &message); err != nil {
+			continue
+		}
+		if message.Origin == b.instanceID {
+			continue
+		}
+
+		data := []byte(message.Data)
+		if message.PayloadID != nil {
+			var stored string
+			err := b.db.QueryRow(ctx, "SELECT payload FROM hub_event_payloads WHERE id = $1", *message.PayloadID).Scan(&stored)
+			if err != nil {
+				log.Println("Broker Payload Error:", err)
+				continue
+			}
+			data = []byte(stored)
+		}
+
+		handler(message.Topic, message.SessionID, data)
+	}
+}
+
+func (b *PostgresBroker) deleteExpiredPayloads(ctx context.Context) {
+	ticker := time.NewTicker(payloadRetention)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := b.db.Exec(ctx, "DELETE FROM hub_event_payloads WHERE created_at < $1", time.Now().Add(-payloadRetention))
+			if err != nil && ctx.Err() == nil {
+				log.Println("Broker Cleanup Error:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/*
+Reconnecting clients tell the server where they left off with a ResumeCursor. Persisted messages carry a
+server-assigned seq that is strictly increasing within a session, so everything after the cursor can be read
+back from the database and replayed before live messages are written.
+
+The client joins the hub before the replay is read, so nothing broadcast in between is lost. Live messages that
+were queued during the replay and are also part of it are skipped by their envelope seq.
+*/
+
+// ResumeCursor is read from the socket query. Only one field is expected; LastSeq is the preferred one.
+type ResumeCursor struct {
+	LastSeq       int64
+	LastMessageID *uuid.UUID
+	Since         *time.Time
+}
+
+// ParseResumeCursor reads the last_seq, last_message_id and since query parameters.
+// Form binding cannot decode UUIDs, so they are parsed here.
+func ParseResumeCursor(query url.Values) (*ResumeCursor, error) {
+	var cursor ResumeCursor
+	if raw := query.Get("last_seq"); raw != "" {
+		lastSeq, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last_seq: %w", err)
+		}
+		cursor.LastSeq = lastSeq
+	}
+	if raw := query.Get("last_message_id"); raw != "" {
+		lastMessageID, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last_message_id: %w", err)
+		}
+		cursor.LastMessageID = &lastMessageID
+	}
+	if raw := query.Get("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since: %w", err)
+		}
+		cursor.Since = &since
+	}
+	return &cursor, nil
+}
+
+// IsSet reports whether the client asked for a replay at all.
+func (c *ResumeCursor) IsSet() bool {
+	return c.LastSeq > 0 || c.LastMessageID != nil || c.Since != nil
+}
+
+// Replayer sends the messages a reconnecting client missed through send, oldest first,
+// and returns the highest seq it sent.
+type Replayer func(send func(envelope *Envelope) error) (int64, error)
+
+// ResumedPayload marks the end of a replay; live messages follow it.
+type ResumedPayload struct {
+	LastSeq int64 `json:"last_seq"`
+	Count   int   `json:"count"`
+}
+
+// NewMessageEnvelope wraps a persisted message together with its seq.
+func NewMessageEnvelope(correlationID string, seq int64, payload any) (*Envelope, error) {
+	envelope, err := NewEnvelope(EventMessage, correlationID, payload)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Seq = seq
+	return envelope, nil
+}
+
+// AlreadyReplayed reports whether a queued message was already sent by a replay up to lastSeq.
+func AlreadyReplayed(data []byte, lastSeq int64) bool {
+	if lastSeq == 0 {
+		return false
+	}
+
+	var frame struct {
+		Seq int64 `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return false
+	}
+	return frame.Seq != 0 && frame.Seq <= lastSeq
+}
+
+// MaxReplayMessages bounds how many missed messages are replayed to a reconnecting client.
+// Readers should ask for one more so that SendReplay can tell the client when the replay was cut short.
+const MaxReplayMessages = 1000
+
+// SendReplay sends messages read after cursor as "message" frames and returns the highest seq sent.
+func SendReplay[T any](send func(envelope *Envelope) error, cursor *ResumeCursor, messages []T, seqOf func(T) int64) (int64, error) {
+	truncated := len(messages) > MaxReplayMessages
+	if truncated {
+		messages = messages[:MaxReplayMessages]
+	}
+
+	lastSeq := cursor.LastSeq
+	for _, message := range messages {
+		envelope, err := NewMessageEnvelope("", seqOf(message), message)
+		if err != nil {
+			return lastSeq, err
+		}
+		if err := send(envelope); err != nil {
+			return lastSeq, err
+		}
+		lastSeq = seqOf(message)
+	}
+
+	if truncated {
+		return lastSeq, send(NewErrorEnvelope("", ErrorCodeReplayTruncated, "too many missed messages, reload the history"))
+	}
+	return lastSeq, nil
+}
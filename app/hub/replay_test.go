@@ -0,0 +1,68 @@
+package hub
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseResumeCursor(t *testing.T) {
+	messageID := uuid.MustParse("6f1c2a52-5d4e-4f7a-9b1e-2c3d4e5f6a7b")
+	since := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    ResumeCursor
+		wantSet bool
+		wantErr bool
+	}{
+		{name: "no cursor", query: "", want: ResumeCursor{}},
+		{name: "last seq", query: "last_seq=42", want: ResumeCursor{LastSeq: 42}, wantSet: true},
+		{name: "last message id", query: "last_message_id=" + messageID.String(), want: ResumeCursor{LastMessageID: &messageID}, wantSet: true},
+		{name: "since", query: "since=2025-03-01T12:30:00Z", want: ResumeCursor{Since: &since}, wantSet: true},
+		{name: "unrelated parameters", query: "sessionID=abc&ticket=xyz", want: ResumeCursor{}},
+		{name: "invalid last seq", query: "last_seq=abc", wantErr: true},
+		{name: "invalid last message id", query: "last_message_id=42", wantErr: true},
+		{name: "invalid since", query: "since=yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cursor, err := ParseResumeCursor(query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseResumeCursor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if cursor.LastSeq != tt.want.LastSeq {
+				t.Errorf("LastSeq = %d, want %d", cursor.LastSeq, tt.want.LastSeq)
+			}
+			if !equalPtr(cursor.LastMessageID, tt.want.LastMessageID) {
+				t.Errorf("LastMessageID = %v, want %v", cursor.LastMessageID, tt.want.LastMessageID)
+			}
+			if (cursor.Since == nil) != (tt.want.Since == nil) || (cursor.Since != nil && !cursor.Since.Equal(*tt.want.Since)) {
+				t.Errorf("Since = %v, want %v", cursor.Since, tt.want.Since)
+			}
+			if cursor.IsSet() != tt.wantSet {
+				t.Errorf("IsSet() = %v, want %v", cursor.IsSet(), tt.wantSet)
+			}
+		})
+	}
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
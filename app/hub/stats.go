@@ -0,0 +1,27 @@
+package hub
+
+type Stats struct {
+	Sessions    int            `json:"sessions"`
+	Connections int            `json:"connections"`
+	PerSession  map[string]int `json:"per_session"`
+	// Totals since the hub was created
+	Joined  uint64 `json:"joined"`
+	Evicted uint64 `json:"evicted"`
+}
+
+func (h *Hub) Stats() Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stats := Stats{
+		Sessions:   len(h.sessions),
+		PerSession: make(map[string]int, len(h.sessions)),
+		Joined:     h.joined.Load(),
+		Evicted:    h.evicted.Load(),
+	}
+	for id, s := range h.sessions {
+		stats.PerSession[id] = s.members
+		stats.Connections += s.members
+	}
+	return stats
+}
@@ -0,0 +1,172 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ServeWebSocket joins conn to the session, writes queued messages and heartbeat pings to it from a dedicated
+// goroutine and passes every frame read from it to handle. It blocks until the connection is closed.
+//
+// Connections that stay silent (no frames and no pongs) for IdleTimeout are closed, and frames larger than
+// MaxMessageSize close the connection with CloseMessageTooBig.
+//
+// See SocketOptions for the hooks around the connection's lifetime.
+func (h *Hub) ServeWebSocket(conn *websocket.Conn, sessionID string, options SocketOptions, handle func(client *Client, data []byte)) {
+	h.serving.Add(1)
+	defer h.serving.Add(-1)
+
+	client := h.Join(sessionID)
+	done := make(chan struct{})
+
+	conn.SetReadLimit(h.config.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(h.config.IdleTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(h.config.IdleTimeout))
+	})
+
+	go func() {
+		defer close(done)
+		h.writePump(conn, client, options.Replay)
+	}()
+
+	if options.OnJoin != nil {
+		options.OnJoin(client)
+	}
+
+	code, reason := 0, ""
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			code, reason = readCloseStatus(err)
+			break
+		}
+		conn.SetReadDeadline(time.Now().Add(h.config.IdleTimeout))
+		handle(client, data)
+	}
+
+	h.LeaveWithStatus(client, code, reason)
+	<-done
+	conn.Close()
+
+	if options.OnLeave != nil {
+		options.OnLeave(client)
+	}
+}
+
+type SocketOptions struct {
+	// Replay, if set, is run by the writer before any queued message and ended with a "resumed" frame
+	Replay Replayer
+	// OnJoin runs once the client is registered, before the first frame is read
+	OnJoin func(client *Client)
+	// OnLeave runs after the connection is closed
+	OnLeave func(client *Client)
+}
+
+// readCloseStatus maps a read error to the close frame the server should answer with.
+func readCloseStatus(err error) (int, string) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, websocket.ErrReadLimit):
+		return websocket.CloseMessageTooBig, CloseReasonTooLarge
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return websocket.ClosePolicyViolation, CloseReasonIdleTimeout
+	case websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure, websocket.CloseNoStatusReceived):
+		log.Println("Read Error:", err)
+	}
+	return 0, ""
+}
+
+func (h *Hub) writePump(conn *websocket.Conn, client *Client, replay Replayer) {
+	ticker := time.NewTicker(h.config.PingInterval)
+	defer ticker.Stop()
+
+	var lastSeq int64
+	if replay != nil {
+		var err error
+		if lastSeq, err = h.replay(conn, replay); err != nil {
+			log.Println("Send Error:", err)
+			h.abandon(conn, client)
+			return
+		}
+	}
+
+	for {
+		select {
+		case data, ok := <-client.Messages():
+			if !ok {
+				code, reason := client.CloseStatus()
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(h.config.WriteTimeout))
+				conn.Close()
+				return
+			}
+			if AlreadyReplayed(data, lastSeq) {
+				continue
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(h.config.WriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Println("Send Error:", err)
+				h.abandon(conn, client)
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.config.WriteTimeout)); err != nil {
+				h.abandon(conn, client)
+				return
+			}
+		}
+	}
+}
+
+// replay writes the missed messages and the closing "resumed" frame. Only write errors are returned;
+// a failing replayer is reported to the client, which keeps receiving live messages.
+func (h *Hub) replay(conn *websocket.Conn, replay Replayer) (int64, error) {
+	count := 0
+	write := func(envelope *Envelope) error {
+		data, err := json.Marshal(envelope)
+		if err != nil {
+			return err
+		}
+		conn.SetWriteDeadline(time.Now().Add(h.config.WriteTimeout))
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return err
+		}
+		if envelope.Type == EventMessage {
+			count++
+		}
+		return nil
+	}
+
+	var writeErr error
+	lastSeq, err := replay(func(envelope *Envelope) error {
+		writeErr = write(envelope)
+		return writeErr
+	})
+	if writeErr != nil {
+		return 0, writeErr
+	}
+	if err != nil {
+		log.Println("Replay Error:", err)
+		return lastSeq, write(NewErrorEnvelope("", ErrorCodeReplayFailed, err.Error()))
+	}
+
+	resumed, err := NewEnvelope(EventResumed, "", ResumedPayload{LastSeq: lastSeq, Count: count})
+	if err != nil {
+		return lastSeq, err
+	}
+	return lastSeq, write(resumed)
+}
+
+// abandon closes a connection that can no longer be written to and drains the client's queue until it leaves.
+func (h *Hub) abandon(conn *websocket.Conn, client *Client) {
+	// Closing the connection unblocks the reader, which then makes the client leave
+	conn.Close()
+	for range client.Messages() {
+	}
+}
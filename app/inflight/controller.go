@@ -0,0 +1,31 @@
+package inflight
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type InflightController struct {
+	inflightService InflightService
+}
+
+func NewInflightController(inflightService InflightService) *InflightController {
+	return &InflightController{inflightService: inflightService}
+}
+
+func (ic *InflightController) GetInterruptedOperations(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	operations, err := ic.inflightService.ReadInterruptedOperations(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, operations)
+}
@@ -0,0 +1,22 @@
+package inflight
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OperationKindChatResponse = "chat_response"
+	OperationKindToolResponse = "tool_response"
+)
+
+type InterruptedOperation struct {
+	ID              uuid.UUID  `json:"id"`
+	Kind            string     `json:"kind"`
+	SessionID       uuid.UUID  `json:"session_id"`
+	SourceMessageID *uuid.UUID `json:"source_message_id"`
+	PartialOutput   string     `json:"partial_output"`
+	StartedAt       time.Time  `json:"started_at"`
+	InterruptedAt   time.Time  `json:"interrupted_at"`
+}
@@ -0,0 +1,18 @@
+package inflight
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupInflightRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool) {
+	inflightService := NewInflightService(c, db)
+	inflightController := NewInflightController(inflightService)
+
+	inflightRoutes := router.Group("/v1/operations")
+	{
+		inflightRoutes.GET("/interrupted/:sessionID", inflightController.GetInterruptedOperations)
+	}
+}
@@ -0,0 +1,66 @@
+package inflight
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type InflightService interface {
+	ReadInterruptedOperations(rctx context.Context, sessionID uuid.UUID) ([]*InterruptedOperation, error)
+	CreateInterruptedOperation(rctx context.Context, operation *InterruptedOperation) error
+}
+
+type inflightService struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func NewInflightService(c context.Context, db *pgxpool.Pool) InflightService {
+	return &inflightService{ctx: c, db: db}
+}
+
+func (s *inflightService) ReadInterruptedOperations(rctx context.Context, sessionID uuid.UUID) ([]*InterruptedOperation, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT id, kind, session_id, source_message_id, partial_output, started_at, interrupted_at
+        FROM interrupted_operations
+        WHERE session_id = $1
+        ORDER BY interrupted_at DESC
+    `, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	operations := []*InterruptedOperation{}
+	for rows.Next() {
+		var operation InterruptedOperation
+		if err := rows.Scan(
+			&operation.ID,
+			&operation.Kind,
+			&operation.SessionID,
+			&operation.SourceMessageID,
+			&operation.PartialOutput,
+			&operation.StartedAt,
+			&operation.InterruptedAt,
+		); err != nil {
+			return nil, err
+		}
+		operations = append(operations, &operation)
+	}
+	return operations, rows.Err()
+}
+
+func (s *inflightService) CreateInterruptedOperation(rctx context.Context, operation *InterruptedOperation) error {
+	if operation.InterruptedAt.IsZero() {
+		operation.InterruptedAt = time.Now()
+	}
+
+	_, err := s.db.Exec(rctx, `
+        INSERT INTO interrupted_operations (id, kind, session_id, source_message_id, partial_output, started_at, interrupted_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, operation.ID, operation.Kind, operation.SessionID, operation.SourceMessageID, operation.PartialOutput, operation.StartedAt, operation.InterruptedAt)
+	return err
+}
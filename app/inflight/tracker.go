@@ -0,0 +1,124 @@
+package inflight
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// interruptGrace is how long cancelled operations get to record themselves as interrupted
+const interruptGrace = 5 * time.Second
+
+/*
+Tracker keeps count of the AI answers and tool runs that outlive the request or frame that started them,
+so that a shutdown can wait for them.
+
+Drain refuses new operations and waits for the running ones until its deadline. Operations still running then
+have their context cancelled; they are expected to stop and call Interrupt, which stores them in
+interrupted_operations together with whatever output they produced so far.
+*/
+type Tracker struct {
+	db     *pgxpool.Pool
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	draining bool
+	running  sync.WaitGroup
+}
+
+func NewTracker(db *pgxpool.Pool) *Tracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Tracker{db: db, ctx: ctx, cancel: cancel}
+}
+
+type Operation struct {
+	tracker   *Tracker
+	kind      string
+	sessionID uuid.UUID
+	sourceID  *uuid.UUID
+	startedAt time.Time
+	finish    sync.Once
+}
+
+// Start registers an operation. Every operation must be ended with Finish.
+func (t *Tracker) Start(kind string, sessionID uuid.UUID, sourceMessageID *uuid.UUID) (*Operation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.draining {
+		return nil, ErrShuttingDown
+	}
+	t.running.Add(1)
+	return &Operation{
+		tracker:   t,
+		kind:      kind,
+		sessionID: sessionID,
+		sourceID:  sourceMessageID,
+		startedAt: time.Now(),
+	}, nil
+}
+
+// Drain stops new operations and waits for the running ones. Once ctx is done the remaining operations are
+// cancelled and get a short grace period to record themselves as interrupted.
+func (t *Tracker) Drain(ctx context.Context) {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	log.Println("Interrupting in-flight operations")
+	t.cancel()
+	select {
+	case <-done:
+	case <-time.After(interruptGrace):
+		log.Println("In-flight operations did not stop in time")
+	}
+}
+
+// Context is cancelled when the operation has to be abandoned for a shutdown.
+func (op *Operation) Context() context.Context {
+	return op.tracker.ctx
+}
+
+func (op *Operation) Interrupted() bool {
+	return op.tracker.ctx.Err() != nil
+}
+
+// Interrupt records the operation as interrupted along with its partial output.
+func (op *Operation) Interrupt(partialOutput string) {
+	err := NewInflightService(context.Background(), op.tracker.db).CreateInterruptedOperation(context.Background(), &InterruptedOperation{
+		ID:              uuid.New(),
+		Kind:            op.kind,
+		SessionID:       op.sessionID,
+		SourceMessageID: op.sourceID,
+		PartialOutput:   partialOutput,
+		StartedAt:       op.startedAt,
+	})
+	if err != nil {
+		log.Println("Failed to record interrupted operation:", err)
+	}
+}
+
+// Finish ends the operation. It is safe to call more than once.
+func (op *Operation) Finish() {
+	op.finish.Do(op.tracker.running.Done)
+}
@@ -0,0 +1,31 @@
+package presence
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PresenceController struct {
+	presenceService PresenceService
+}
+
+func NewPresenceController(presenceService PresenceService) *PresenceController {
+	return &PresenceController{presenceService: presenceService}
+}
+
+func (pc *PresenceController) GetParticipants(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	participants, err := pc.presenceService.ReadParticipants(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, participants)
+}
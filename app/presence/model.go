@@ -0,0 +1,52 @@
+package presence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Chat socket frame sent by clients while their user types, carrying a TypingDTO
+const EventTyping = "typing"
+
+const (
+	ParticipantKindUser      = "user"
+	ParticipantKindAssistant = "assistant"
+	ParticipantKindTool      = "tool"
+)
+
+const (
+	ParticipantStateIdle     = "idle"
+	ParticipantStateTyping   = "typing"
+	ParticipantStateThinking = "thinking"
+	ParticipantStateRunning  = "running"
+)
+
+const (
+	PresenceActionSnapshot = "snapshot"
+	PresenceActionJoin     = "join"
+	PresenceActionLeave    = "leave"
+	PresenceActionState    = "state"
+)
+
+type Participant struct {
+	ID        uuid.UUID `json:"id"`
+	SessionID uuid.UUID `json:"session_id"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	JoinedAt  time.Time `json:"joined_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PresenceEvent is the payload of "presence" envelopes. A snapshot lists every participant
+// and is sent to a client when it joins; the other actions carry the participant that changed.
+type PresenceEvent struct {
+	Action       string         `json:"action"`
+	Participant  *Participant   `json:"participant,omitempty"`
+	Participants []*Participant `json:"participants,omitempty"`
+}
+
+type TypingDTO struct {
+	Typing bool `json:"typing"`
+}
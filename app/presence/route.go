@@ -0,0 +1,18 @@
+package presence
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupPresenceRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool) {
+	presenceService := NewPresenceService(c, db)
+	presenceController := NewPresenceController(presenceService)
+
+	presenceRoutes := router.Group("/v1/presence")
+	{
+		presenceRoutes.GET("/:sessionID", presenceController.GetParticipants)
+	}
+}
@@ -0,0 +1,215 @@
+package presence
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrParticipantNotFound = errors.New("participant not found")
+
+// instanceID marks the participants whose connections are held by this server instance
+var instanceID = uuid.NewString()
+
+const participantColumns = `id, session_id, kind, name, state, joined_at, updated_at`
+
+type PresenceService interface {
+	ReadParticipants(rctx context.Context, sessionID uuid.UUID) ([]*Participant, error)
+	JoinSession(rctx context.Context, sessionID uuid.UUID, name string) (*Participant, error)
+	LeaveSession(rctx context.Context, id uuid.UUID) (*Participant, error)
+	SetTyping(rctx context.Context, id uuid.UUID, typing bool, ttl time.Duration) (*Participant, bool, error)
+	ExpireTyping(rctx context.Context) ([]*Participant, error)
+	SetAgentState(rctx context.Context, sessionID uuid.UUID, kind string, state string) (*Participant, error)
+	RefreshInstance(rctx context.Context) error
+	DeleteExpired(rctx context.Context, ttl time.Duration) ([]*Participant, error)
+}
+
+type presenceService struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func NewPresenceService(c context.Context, db *pgxpool.Pool) PresenceService {
+	return &presenceService{ctx: c, db: db}
+}
+
+func (s *presenceService) ReadParticipants(rctx context.Context, sessionID uuid.UUID) ([]*Participant, error) {
+	rows, err := s.db.Query(rctx, "SELECT "+participantColumns+" FROM session_participants WHERE session_id = $1 ORDER BY joined_at", sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanParticipants(rows)
+}
+
+func (s *presenceService) JoinSession(rctx context.Context, sessionID uuid.UUID, name string) (*Participant, error) {
+	now := time.Now()
+	participant := &Participant{
+		ID:        uuid.New(),
+		SessionID: sessionID,
+		Kind:      ParticipantKindUser,
+		Name:      name,
+		State:     ParticipantStateIdle,
+		JoinedAt:  now,
+		UpdatedAt: now,
+	}
+
+	_, err := s.db.Exec(rctx, `
+        INSERT INTO session_participants (id, session_id, kind, name, state, instance_id, joined_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, participant.ID, participant.SessionID, participant.Kind, participant.Name, participant.State, instanceID, participant.JoinedAt, participant.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return participant, nil
+}
+
+// LeaveSession removes the participant and returns it as it was last seen
+func (s *presenceService) LeaveSession(rctx context.Context, id uuid.UUID) (*Participant, error) {
+	participant, err := scanParticipant(s.db.QueryRow(rctx, "DELETE FROM session_participants WHERE id = $1 RETURNING "+participantColumns, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrParticipantNotFound
+	}
+	return participant, err
+}
+
+// SetTyping switches a user between typing and idle and reports whether the state changed.
+// Typing lasts for ttl unless it is reported again, so a client that disappears mid-sentence stops typing.
+func (s *presenceService) SetTyping(rctx context.Context, id uuid.UUID, typing bool, ttl time.Duration) (*Participant, bool, error) {
+	state, typingUntil := ParticipantStateIdle, (*time.Time)(nil)
+	if typing {
+		until := time.Now().Add(ttl)
+		state, typingUntil = ParticipantStateTyping, &until
+	}
+
+	var changed bool
+	participant, err := database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*Participant, error) {
+		var previous string
+		if err := tx.QueryRow(rctx, "SELECT state FROM session_participants WHERE id = $1 FOR UPDATE", id).Scan(&previous); err != nil {
+			return nil, err
+		}
+		changed = previous != state
+
+		return scanParticipant(tx.QueryRow(rctx, `
+            UPDATE session_participants SET state = $2, typing_until = $3, updated_at = $4
+            WHERE id = $1
+            RETURNING `+participantColumns, id, state, typingUntil, time.Now()))
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, ErrParticipantNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return participant, changed, nil
+}
+
+// ExpireTyping moves users whose typing was not reported again in time back to idle and returns them
+func (s *presenceService) ExpireTyping(rctx context.Context) ([]*Participant, error) {
+	rows, err := s.db.Query(rctx, `
+        UPDATE session_participants SET state = $1, typing_until = NULL
+        WHERE state = $2 AND typing_until < $3
+        RETURNING `+participantColumns, ParticipantStateIdle, ParticipantStateTyping, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanParticipants(rows)
+}
+
+// SetAgentState records what the assistant or the tool runner is doing in the session.
+// Agents are only listed while they work. Every working state starts an operation and every idle state
+// ends one; the agent is removed when its last operation ends, so concurrent answers do not clear each other.
+// While other operations continue, the idle state returns no participant.
+func (s *presenceService) SetAgentState(rctx context.Context, sessionID uuid.UUID, kind string, state string) (*Participant, error) {
+	if state == ParticipantStateIdle {
+		return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*Participant, error) {
+			var operations int
+			err := tx.QueryRow(rctx, `
+                UPDATE session_participants SET operations = operations - 1
+                WHERE session_id = $1 AND kind = $2
+                RETURNING operations
+            `, sessionID, kind).Scan(&operations)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, ErrParticipantNotFound
+			}
+			if err != nil || operations > 0 {
+				return nil, err
+			}
+
+			participant, err := scanParticipant(tx.QueryRow(rctx, `
+                DELETE FROM session_participants WHERE session_id = $1 AND kind = $2
+                RETURNING `+participantColumns, sessionID, kind))
+			if err != nil {
+				return nil, err
+			}
+			participant.State = ParticipantStateIdle
+			return participant, nil
+		})
+	}
+
+	now := time.Now()
+	return scanParticipant(s.db.QueryRow(rctx, `
+        INSERT INTO session_participants (id, session_id, kind, name, state, instance_id, joined_at, updated_at, operations)
+        VALUES ($1, $2, $3, $3, $4, $5, $6, $6, 1)
+        ON CONFLICT (session_id, kind) WHERE kind <> 'user'
+        DO UPDATE SET
+            state = EXCLUDED.state,
+            instance_id = EXCLUDED.instance_id,
+            updated_at = EXCLUDED.updated_at,
+            operations = session_participants.operations + 1
+        RETURNING `+participantColumns, uuid.New(), sessionID, kind, state, instanceID, now))
+}
+
+// RefreshInstance marks every participant held by this instance as still connected
+func (s *presenceService) RefreshInstance(rctx context.Context) error {
+	_, err := s.db.Exec(rctx, "UPDATE session_participants SET updated_at = $2 WHERE instance_id = $1", instanceID, time.Now())
+	return err
+}
+
+// DeleteExpired removes participants whose instance stopped refreshing them, e.g. after a crash
+func (s *presenceService) DeleteExpired(rctx context.Context, ttl time.Duration) ([]*Participant, error) {
+	rows, err := s.db.Query(rctx, "DELETE FROM session_participants WHERE updated_at < $1 RETURNING "+participantColumns, time.Now().Add(-ttl))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanParticipants(rows)
+}
+
+func scanParticipants(rows pgx.Rows) ([]*Participant, error) {
+	participants := []*Participant{}
+	for rows.Next() {
+		participant, err := scanParticipant(rows)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, participant)
+	}
+	return participants, rows.Err()
+}
+
+func scanParticipant(row pgx.Row) (*Participant, error) {
+	var participant Participant
+	err := row.Scan(
+		&participant.ID,
+		&participant.SessionID,
+		&participant.Kind,
+		&participant.Name,
+		&participant.State,
+		&participant.JoinedAt,
+		&participant.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &participant, nil
+}
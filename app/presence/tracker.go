@@ -0,0 +1,168 @@
+package presence
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+Tracker keeps the participants of each session in session_participants and announces every change
+as a "presence" envelope on the hub, so it reaches every instance.
+
+Users join and leave with their chat socket and report typing through it; typing expires after typingTimeout.
+The assistant and the tool runner are listed while any of their operations runs, as set by the chat and tool handlers.
+Presence is best effort: failures are logged and never break the connection or the answer.
+*/
+// typingTimeout is how long a user stays typing without reporting it again
+const typingTimeout = 10 * time.Second
+
+type Tracker struct {
+	db  *pgxpool.Pool
+	hub *hub.Hub
+}
+
+func NewTracker(db *pgxpool.Pool, h *hub.Hub) *Tracker {
+	return &Tracker{db: db, hub: h}
+}
+
+// Join adds the client's user to its session, sends it the current participants and announces it to the others.
+// It returns nil if the user could not be added.
+func (t *Tracker) Join(client *hub.Client, name string) *Participant {
+	ctx := context.Background()
+	presenceService := NewPresenceService(ctx, t.db)
+
+	sessionID, err := uuid.Parse(client.SessionID())
+	if err != nil {
+		log.Println("Presence Join Error:", err)
+		return nil
+	}
+
+	participant, err := presenceService.JoinSession(ctx, sessionID, name)
+	if err != nil {
+		log.Println("Presence Join Error:", err)
+		return nil
+	}
+
+	participants, err := presenceService.ReadParticipants(ctx, sessionID)
+	if err != nil {
+		log.Println("Presence Read Error:", err)
+	} else {
+		snapshot, err := hub.NewEnvelope(hub.EventPresence, "", PresenceEvent{Action: PresenceActionSnapshot, Participants: participants})
+		if err == nil {
+			t.hub.SendTo(client, snapshot)
+		}
+	}
+
+	t.announce(PresenceActionJoin, participant)
+	return participant
+}
+
+// Leave removes a participant returned by Join. A nil participant is ignored.
+func (t *Tracker) Leave(participant *Participant) {
+	if participant == nil {
+		return
+	}
+
+	left, err := NewPresenceService(context.Background(), t.db).LeaveSession(context.Background(), participant.ID)
+	if err != nil {
+		if !errors.Is(err, ErrParticipantNotFound) {
+			log.Println("Presence Leave Error:", err)
+		}
+		return
+	}
+	t.announce(PresenceActionLeave, left)
+}
+
+// SetTyping switches a user between typing and idle, announcing only actual changes.
+// Reporting typing again extends it by typingTimeout.
+func (t *Tracker) SetTyping(participant *Participant, typing bool) error {
+	if participant == nil {
+		return ErrParticipantNotFound
+	}
+	if !typing && participant.State == ParticipantStateIdle {
+		return nil
+	}
+
+	updated, changed, err := NewPresenceService(context.Background(), t.db).SetTyping(context.Background(), participant.ID, typing, typingTimeout)
+	if err != nil {
+		return err
+	}
+	participant.State = updated.State
+	if changed {
+		t.announce(PresenceActionState, updated)
+	}
+	return nil
+}
+
+// SetAgentState reports that the assistant or the tool runner of a session started or stopped working.
+func (t *Tracker) SetAgentState(sessionID uuid.UUID, kind string, state string) {
+	participant, err := NewPresenceService(context.Background(), t.db).SetAgentState(context.Background(), sessionID, kind, state)
+	if errors.Is(err, ErrParticipantNotFound) {
+		return
+	}
+	if err != nil {
+		log.Println("Presence State Error:", err)
+		return
+	}
+	// Other operations of the agent are still running
+	if participant == nil {
+		return
+	}
+	t.announce(PresenceActionState, participant)
+}
+
+// Run keeps the participants of this instance alive and removes those of instances that stopped,
+// every interval until ctx is cancelled. Expired typing is reset more often, every half typingTimeout.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	presenceService := NewPresenceService(ctx, t.db)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	typingTicker := time.NewTicker(typingTimeout / 2)
+	defer typingTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-typingTicker.C:
+			expired, err := presenceService.ExpireTyping(ctx)
+			if err != nil {
+				log.Println("Presence Typing Error:", err)
+			}
+			for _, participant := range expired {
+				t.announce(PresenceActionState, participant)
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		if err := presenceService.RefreshInstance(ctx); err != nil {
+			log.Println("Presence Refresh Error:", err)
+		}
+
+		expired, err := presenceService.DeleteExpired(ctx, 3*interval)
+		if err != nil {
+			log.Println("Presence Cleanup Error:", err)
+			continue
+		}
+		for _, participant := range expired {
+			t.announce(PresenceActionLeave, participant)
+		}
+	}
+}
+
+func (t *Tracker) announce(action string, participant *Participant) {
+	envelope, err := hub.NewEnvelope(hub.EventPresence, "", PresenceEvent{Action: action, Participant: participant})
+	if err == nil {
+		err = t.hub.Broadcast(participant.SessionID.String(), envelope)
+	}
+	if err != nil {
+		log.Println("Broadcast Error:", err)
+	}
+}
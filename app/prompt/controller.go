@@ -0,0 +1,125 @@
+package prompt
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type PromptController struct {
+	promptService PromptService
+}
+
+func NewPromptController(promptService PromptService) *PromptController {
+	return &PromptController{promptService: promptService}
+}
+
+func (pc *PromptController) GetTemplates(c *gin.Context) {
+	templates, err := pc.promptService.ReadAllTemplates(c.Request.Context(), c.Query("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+func (pc *PromptController) GetTemplate(c *gin.Context) {
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promptTemplate, err := pc.promptService.ReadTemplate(c.Request.Context(), templateID)
+	if err != nil {
+		c.JSON(promptErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, promptTemplate)
+}
+
+func (pc *PromptController) CreateTemplate(c *gin.Context) {
+	var dto CreatePromptTemplateDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promptTemplate, err := pc.promptService.CreateTemplate(c.Request.Context(), &dto)
+	if err != nil {
+		c.JSON(promptErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, promptTemplate)
+}
+
+func (pc *PromptController) UpdateTemplate(c *gin.Context) {
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto UpdatePromptTemplateDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promptTemplate, err := pc.promptService.UpdateTemplate(c.Request.Context(), templateID, &dto)
+	if err != nil {
+		c.JSON(promptErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, promptTemplate)
+}
+
+func (pc *PromptController) DeleteTemplate(c *gin.Context) {
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := pc.promptService.DeleteTemplate(c.Request.Context(), templateID); err != nil {
+		c.JSON(promptErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusNoContent, gin.H{})
+}
+
+func (pc *PromptController) RenderTemplate(c *gin.Context) {
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var vars PromptVariables
+	if err := c.ShouldBindJSON(&vars); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rendered, err := pc.promptService.RenderTemplate(c.Request.Context(), templateID, &vars)
+	if err != nil {
+		c.JSON(promptErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rendered)
+}
+
+func promptErrorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, ErrTemplateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidTemplate), errors.As(err, &validationErrors):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,54 @@
+package prompt
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTemplateBody is used when no active template exists in the database.
+const DefaultTemplateBody = `
+				You are a helpful assistant that finds the best tool for the user. 
+				Extract the user intention of user and find the best tool for the user.
+				Selected Tool is {{.ToolName}}.
+				Tell user about intention and why this tool is selected.
+				Keep kind and helpful.
+			`
+
+type PromptTemplate struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Version   int        `json:"version"`
+	ToolID    *uuid.UUID `json:"tool_id"`
+	Body      string     `json:"body"`
+	IsActive  bool       `json:"is_active"`
+	Weight    int        `json:"weight"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type CreatePromptTemplateDTO struct {
+	Name     string     `json:"name" validate:"required"`
+	ToolID   *uuid.UUID `json:"tool_id"`
+	Body     string     `json:"body" validate:"required"`
+	IsActive *bool      `json:"is_active"`
+	Weight   *int       `json:"weight" validate:"omitempty,min=1"`
+}
+
+type UpdatePromptTemplateDTO struct {
+	IsActive *bool `json:"is_active"`
+	Weight   *int  `json:"weight" validate:"omitempty,min=1"`
+}
+
+// PromptVariables are the values available to templates, e.g. {{.ToolName}}.
+type PromptVariables struct {
+	ToolName        string `json:"tool_name"`
+	ToolDescription string `json:"tool_description"`
+	ToolInterface   string `json:"tool_interface"`
+	SessionName     string `json:"session_name"`
+}
+
+type RenderedPrompt struct {
+	TemplateID *uuid.UUID `json:"template_id"`
+	Version    int        `json:"version"`
+	Text       string     `json:"text"`
+}
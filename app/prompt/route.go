@@ -0,0 +1,23 @@
+package prompt
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupPromptRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool) {
+	promptService := NewPromptService(c, db)
+	promptController := NewPromptController(promptService)
+
+	promptRoutes := router.Group("/v1/prompt")
+	{
+		promptRoutes.GET("", promptController.GetTemplates)
+		promptRoutes.GET("/:id", promptController.GetTemplate)
+		promptRoutes.POST("", promptController.CreateTemplate)
+		promptRoutes.PATCH("/:id", promptController.UpdateTemplate)
+		promptRoutes.DELETE("/:id", promptController.DeleteTemplate)
+		promptRoutes.POST("/:id/render", promptController.RenderTemplate)
+	}
+}
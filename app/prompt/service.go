@@ -0,0 +1,283 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"text/template"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/database"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrTemplateNotFound = errors.New("prompt template not found")
+var ErrInvalidTemplate = errors.New("invalid prompt template")
+
+const promptTemplateColumns = `id, name, version, tool_id, body, is_active, weight, created_at`
+
+type PromptService interface {
+	ReadAllTemplates(rctx context.Context, name string) ([]*PromptTemplate, error)
+	ReadTemplate(rctx context.Context, id uuid.UUID) (*PromptTemplate, error)
+	CreateTemplate(rctx context.Context, dto *CreatePromptTemplateDTO) (*PromptTemplate, error)
+	UpdateTemplate(rctx context.Context, id uuid.UUID, dto *UpdatePromptTemplateDTO) (*PromptTemplate, error)
+	DeleteTemplate(rctx context.Context, id uuid.UUID) error
+	RenderForTool(rctx context.Context, toolID uuid.UUID, vars *PromptVariables) (*RenderedPrompt, error)
+	RenderTemplate(rctx context.Context, id uuid.UUID, vars *PromptVariables) (*RenderedPrompt, error)
+}
+
+type promptService struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func NewPromptService(c context.Context, db *pgxpool.Pool) PromptService {
+	return &promptService{ctx: c, db: db}
+}
+
+func (s *promptService) ReadAllTemplates(rctx context.Context, name string) ([]*PromptTemplate, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT `+promptTemplateColumns+`
+        FROM prompt_templates
+        WHERE $1 = '' OR name = $1
+        ORDER BY name ASC, version DESC
+    `, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPromptTemplates(rows)
+}
+
+func (s *promptService) ReadTemplate(rctx context.Context, id uuid.UUID) (*PromptTemplate, error) {
+	promptTemplate, err := scanPromptTemplate(s.db.QueryRow(rctx, "SELECT "+promptTemplateColumns+" FROM prompt_templates WHERE id = $1", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	return promptTemplate, err
+}
+
+// CreateTemplate stores a new version of the named template. Existing versions are never modified.
+func (s *promptService) CreateTemplate(rctx context.Context, dto *CreatePromptTemplateDTO) (*PromptTemplate, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("prompt template validation failed: %w", err)
+	}
+
+	// A dry run catches references to unknown variables, which parsing alone accepts
+	if _, err := render(dto.Name, dto.Body, &PromptVariables{}); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+	}
+
+	promptTemplate := &PromptTemplate{
+		ID:        uuid.New(),
+		Name:      dto.Name,
+		ToolID:    dto.ToolID,
+		Body:      dto.Body,
+		IsActive:  true,
+		Weight:    1,
+		CreatedAt: time.Now(),
+	}
+	if dto.IsActive != nil {
+		promptTemplate.IsActive = *dto.IsActive
+	}
+	if dto.Weight != nil {
+		promptTemplate.Weight = *dto.Weight
+	}
+
+	err := database.WithTx(rctx, s.db, func(tx pgx.Tx) error {
+		// Serialize version numbering per template name
+		if _, err := tx.Exec(rctx, "SELECT pg_advisory_xact_lock(hashtext($1))", dto.Name); err != nil {
+			return err
+		}
+
+		err := tx.QueryRow(rctx, "SELECT COALESCE(MAX(version), 0) + 1 FROM prompt_templates WHERE name = $1", dto.Name).
+			Scan(&promptTemplate.Version)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(rctx, `
+            INSERT INTO prompt_templates (`+promptTemplateColumns+`)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        `,
+			promptTemplate.ID,
+			promptTemplate.Name,
+			promptTemplate.Version,
+			promptTemplate.ToolID,
+			promptTemplate.Body,
+			promptTemplate.IsActive,
+			promptTemplate.Weight,
+			promptTemplate.CreatedAt,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return promptTemplate, nil
+}
+
+func (s *promptService) UpdateTemplate(rctx context.Context, id uuid.UUID, dto *UpdatePromptTemplateDTO) (*PromptTemplate, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("prompt template validation failed: %w", err)
+	}
+
+	promptTemplate, err := scanPromptTemplate(s.db.QueryRow(rctx, `
+        UPDATE prompt_templates
+        SET is_active = COALESCE($2, is_active), weight = COALESCE($3, weight)
+        WHERE id = $1
+        RETURNING `+promptTemplateColumns,
+		id, dto.IsActive, dto.Weight,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	return promptTemplate, err
+}
+
+// DeleteTemplate deactivates the template instead of removing it, because chat messages keep
+// pointing at the version that produced them.
+func (s *promptService) DeleteTemplate(rctx context.Context, id uuid.UUID) error {
+	result, err := s.db.Exec(rctx, "UPDATE prompt_templates SET is_active = false WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// RenderForTool picks an active template for the tool, falling back to tool-independent templates and then
+// to DefaultTemplateBody. When several versions are active they are chosen at random by weight (A/B mode).
+// A chosen template that fails to render is logged and replaced by DefaultTemplateBody, so the answer still goes out.
+func (s *promptService) RenderForTool(rctx context.Context, toolID uuid.UUID, vars *PromptVariables) (*RenderedPrompt, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT `+promptTemplateColumns+`
+        FROM prompt_templates
+        WHERE is_active AND (tool_id = $1 OR tool_id IS NULL)
+    `, toolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates, err := scanPromptTemplates(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var toolTemplates, defaultTemplates []*PromptTemplate
+	for _, candidate := range candidates {
+		if candidate.ToolID != nil {
+			toolTemplates = append(toolTemplates, candidate)
+		} else {
+			defaultTemplates = append(defaultTemplates, candidate)
+		}
+	}
+
+	chosen := pickWeighted(toolTemplates)
+	if chosen == nil {
+		chosen = pickWeighted(defaultTemplates)
+	}
+	if chosen != nil {
+		rendered, err := renderTemplate(chosen, vars)
+		if err == nil {
+			return rendered, nil
+		}
+		log.Println("Falling back to the default prompt template:", err)
+	}
+
+	text, err := render("default", DefaultTemplateBody, vars)
+	if err != nil {
+		return nil, err
+	}
+	return &RenderedPrompt{Text: text}, nil
+}
+
+func (s *promptService) RenderTemplate(rctx context.Context, id uuid.UUID, vars *PromptVariables) (*RenderedPrompt, error) {
+	promptTemplate, err := s.ReadTemplate(rctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return renderTemplate(promptTemplate, vars)
+}
+
+func renderTemplate(promptTemplate *PromptTemplate, vars *PromptVariables) (*RenderedPrompt, error) {
+	text, err := render(promptTemplate.Name, promptTemplate.Body, vars)
+	if err != nil {
+		return nil, err
+	}
+	return &RenderedPrompt{TemplateID: &promptTemplate.ID, Version: promptTemplate.Version, Text: text}, nil
+}
+
+func render(name, body string, vars *PromptVariables) (string, error) {
+	parsed, err := template.New(name).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse prompt template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := parsed.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to render prompt template %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+func pickWeighted(templates []*PromptTemplate) *PromptTemplate {
+	total := 0
+	for _, t := range templates {
+		total += t.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	n := rand.Intn(total)
+	for _, t := range templates {
+		if n < t.Weight {
+			return t
+		}
+		n -= t.Weight
+	}
+	return nil
+}
+
+func scanPromptTemplates(rows pgx.Rows) ([]*PromptTemplate, error) {
+	promptTemplates := []*PromptTemplate{}
+	for rows.Next() {
+		promptTemplate, err := scanPromptTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		promptTemplates = append(promptTemplates, promptTemplate)
+	}
+	return promptTemplates, rows.Err()
+}
+
+func scanPromptTemplate(row pgx.Row) (*PromptTemplate, error) {
+	var promptTemplate PromptTemplate
+	err := row.Scan(
+		&promptTemplate.ID,
+		&promptTemplate.Name,
+		&promptTemplate.Version,
+		&promptTemplate.ToolID,
+		&promptTemplate.Body,
+		&promptTemplate.IsActive,
+		&promptTemplate.Weight,
+		&promptTemplate.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &promptTemplate, nil
+}
@@ -0,0 +1,78 @@
+package prompt
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPickWeighted(t *testing.T) {
+	heavy := &PromptTemplate{ID: uuid.New(), Weight: 1}
+	disabled := &PromptTemplate{ID: uuid.New(), Weight: 0}
+
+	tests := []struct {
+		name      string
+		templates []*PromptTemplate
+		want      *PromptTemplate
+	}{
+		{name: "no templates", templates: nil, want: nil},
+		{name: "only zero weights", templates: []*PromptTemplate{disabled}, want: nil},
+		{name: "single template", templates: []*PromptTemplate{heavy}, want: heavy},
+		{name: "zero weight is never picked", templates: []*PromptTemplate{disabled, heavy, disabled}, want: heavy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := pickWeighted(tt.templates); got != tt.want {
+					t.Fatalf("pickWeighted() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPickWeightedDistribution(t *testing.T) {
+	a := &PromptTemplate{ID: uuid.New(), Weight: 3}
+	b := &PromptTemplate{ID: uuid.New(), Weight: 1}
+	templates := []*PromptTemplate{a, b}
+
+	const draws = 20000
+	picks := map[*PromptTemplate]int{}
+	for i := 0; i < draws; i++ {
+		picks[pickWeighted(templates)]++
+	}
+
+	// A 3:1 split within a few percent; the tolerance keeps the test stable across seeds
+	if share := float64(picks[a]) / draws; share < 0.70 || share > 0.80 {
+		t.Errorf("template with weight 3 picked %.2f of the time, want about 0.75", share)
+	}
+	if picks[nil] != 0 {
+		t.Errorf("pickWeighted() returned nil %d times", picks[nil])
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "known variable", body: "Tool {{.ToolName}}", want: "Tool docking"},
+		{name: "unknown variable", body: "Tool {{.Missing}}", wantErr: true},
+		{name: "parse error", body: "Tool {{.ToolName", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := render(tt.name, tt.body, &PromptVariables{ToolName: "docking"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
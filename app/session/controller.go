@@ -1,9 +1,11 @@
 package session
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	validator "github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
@@ -16,12 +18,41 @@ func NewSessionController(sessionService SessionService) *SessionController {
 }
 
 func (sc *SessionController) GetSessions(c *gin.Context) {
-	sessions, err := sc.sessionService.ReadAllSessions(c.Request.Context())
+	var query SessionQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := sc.sessionService.ReadSessions(c.Request.Context(), &query)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	// The body stays a plain list; the next page is linked from the header
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	c.JSON(http.StatusOK, page.Sessions)
+}
+
+func (sc *SessionController) GetSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := sc.sessionService.ReadSession(c.Request.Context(), sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, sessions)
+	c.JSON(http.StatusOK, session)
 }
 
 func (sc *SessionController) CreateSession(c *gin.Context) {
@@ -35,6 +66,63 @@ func (sc *SessionController) CreateSession(c *gin.Context) {
 	c.JSON(http.StatusOK, session)
 }
 
+func (sc *SessionController) RenameSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto RenameSessionDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := sc.sessionService.RenameSession(c.Request.Context(), sessionID, &dto)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+func (sc *SessionController) UpdateSessionStatus(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto UpdateSessionStatusDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := sc.sessionService.UpdateSessionStatus(c.Request.Context(), sessionID, &dto)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+func (sc *SessionController) GetStatusHistory(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := sc.sessionService.ReadStatusHistory(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
 func (sc *SessionController) DeleteSession(c *gin.Context) {
 	sessionID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -43,9 +131,95 @@ func (sc *SessionController) DeleteSession(c *gin.Context) {
 	}
 
 	if err := sc.sessionService.DeleteSession(c.Request.Context(), sessionID); err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session moved to trash"})
+}
+
+func (sc *SessionController) GetDeletedSessions(c *gin.Context) {
+	sessions, err := sc.sessionService.ReadDeletedSessions(c.Request.Context())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (sc *SessionController) RestoreSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := sc.sessionService.RestoreSession(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+func (sc *SessionController) UpdateAnnotations(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "session deleted"})
+	var dto UpdateAnnotationsDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := sc.sessionService.UpdateAnnotations(c.Request.Context(), sessionID, &dto)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+func (sc *SessionController) GetTags(c *gin.Context) {
+	tags, err := sc.sessionService.ReadTags(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+func (sc *SessionController) GetMetadataKeys(c *gin.Context) {
+	keys, err := sc.sessionService.ReadMetadataKeys(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (sc *SessionController) GetMetadataValues(c *gin.Context) {
+	values, err := sc.sessionService.ReadMetadataValues(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, values)
+}
+
+func sessionErrorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidTransition):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidCursor), errors.Is(err, ErrInvalidMetadata), errors.As(err, &validationErrors):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
 }
@@ -0,0 +1,47 @@
+package session
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sessionCursor is the position after the last session of a page. It records the sort it was made for,
+// so that it is never applied to a list sorted differently.
+type sessionCursor struct {
+	Sort       string    `json:"s"`
+	Descending bool      `json:"d"`
+	Time       time.Time `json:"t"`
+	ID         uuid.UUID `json:"i"`
+}
+
+func encodeSessionCursor(last *Session, sort string, descending bool) (string, error) {
+	cursor := sessionCursor{Sort: sort, Descending: descending, Time: last.CreatedAt, ID: last.ID}
+	if sort == SessionSortUpdatedAt && last.UpdatedAt != nil {
+		cursor.Time = *last.UpdatedAt
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeSessionCursor(encoded string, sort string, descending bool) (*sessionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor sessionCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sort || cursor.Descending != descending {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -7,14 +7,105 @@ import (
 )
 
 const (
-	SessionStatusActive = "active"
+	SessionStatusActive   = "active"
+	SessionStatusPaused   = "paused"
+	SessionStatusArchived = "archived"
+	SessionStatusClosed   = "closed"
 )
 
+// sessionTransitions lists the statuses each status may change to
+var sessionTransitions = map[string][]string{
+	SessionStatusActive:   {SessionStatusPaused, SessionStatusArchived, SessionStatusClosed},
+	SessionStatusPaused:   {SessionStatusActive, SessionStatusArchived, SessionStatusClosed},
+	SessionStatusArchived: {SessionStatusActive, SessionStatusClosed},
+	SessionStatusClosed:   {SessionStatusArchived},
+}
+
 type Session struct {
-	ID             uuid.UUID  `json:"id"`
-	Name           string     `json:"name"`
-	Status         string     `json:"status"`
-	ToolStatus     *string    `json:"tool_status"`
-	AssignedToolID *uuid.UUID `json:"assigned_tool_id"`
-	CreatedAt      time.Time  `json:"created_at"`
+	ID              uuid.UUID      `json:"id"`
+	Name            string         `json:"name"`
+	Status          string         `json:"status"`
+	ToolStatus      *string        `json:"tool_status"`
+	AssignedToolID  *uuid.UUID     `json:"assigned_tool_id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       *time.Time     `json:"updated_at"`
+	StatusChangedAt *time.Time     `json:"status_changed_at"`
+	DeletedAt       *time.Time     `json:"deleted_at"`
+	SourceSessionID *uuid.UUID     `json:"source_session_id"`
+	TemplateID      *uuid.UUID     `json:"template_id"`
+	Tags            []string       `json:"tags"`
+	Notes           string         `json:"notes"`
+	Metadata        map[string]any `json:"metadata"`
+	// Title and Summary are generated from the conversation and refreshed as it grows
+	Title                  *string    `json:"title"`
+	Summary                *string    `json:"summary"`
+	SummarizedAt           *time.Time `json:"summarized_at"`
+	SummarizedMessageCount int        `json:"summarized_message_count"`
+}
+
+type RenameSessionDTO struct {
+	Name string `json:"name" validate:"required,max=200"`
+}
+
+// UpdateAnnotationsDTO changes only the fields that are set. Tags and notes replace the current ones;
+// metadata is merged into the current map and keys set to null are removed.
+type UpdateAnnotationsDTO struct {
+	Tags     *[]string      `json:"tags" validate:"omitempty,max=50,dive,required,max=50"`
+	Notes    *string        `json:"notes" validate:"omitempty,max=100000"`
+	Metadata map[string]any `json:"metadata" validate:"omitempty,max=100,dive,keys,required,max=100,endkeys"`
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type MetadataKeyCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type MetadataValueCount struct {
+	Value any `json:"value"`
+	Count int `json:"count"`
+}
+
+type UpdateSessionStatusDTO struct {
+	Status string `json:"status" validate:"required,oneof=active paused archived closed"`
+}
+
+type SessionStatusTransition struct {
+	ID         uuid.UUID `json:"id"`
+	SessionID  uuid.UUID `json:"session_id"`
+	FromStatus *string   `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+const (
+	SessionSortCreatedAt = "created_at"
+	SessionSortUpdatedAt = "updated_at"
+)
+
+// SessionQuery is bound from the query of the session list. From and To bound the time sorted by,
+// and Query searches session names and chat message content. Sessions match when they have every Tag
+// and their metadata contains the Metadata JSON object.
+type SessionQuery struct {
+	Cursor   string     `form:"cursor"`
+	Limit    int        `form:"limit" validate:"omitempty,min=1,max=200"`
+	Sort     string     `form:"sort" validate:"omitempty,oneof=created_at updated_at"`
+	Order    string     `form:"order" validate:"omitempty,oneof=asc desc"`
+	Status   []string   `form:"status" validate:"omitempty,dive,oneof=active paused archived closed"`
+	ToolID   string     `form:"tool_id" validate:"omitempty,uuid"`
+	From     *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Query    string     `form:"q"`
+	Tag      []string   `form:"tag" validate:"omitempty,dive,required,max=50"`
+	Metadata string     `form:"metadata"`
+}
+
+// SessionPage is one page of the session list. NextCursor is empty on the last page.
+type SessionPage struct {
+	Sessions   []*Session
+	NextCursor string
 }
@@ -14,7 +14,17 @@ func SetupSessionRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool)
 	sessionRoutes := router.Group("/v1/session")
 	{
 		sessionRoutes.GET("", sessionController.GetSessions)
+		sessionRoutes.GET("/trash", sessionController.GetDeletedSessions)
+		sessionRoutes.POST("/trash/:id/restore", sessionController.RestoreSession)
+		sessionRoutes.GET("/tags", sessionController.GetTags)
+		sessionRoutes.GET("/metadata", sessionController.GetMetadataKeys)
+		sessionRoutes.GET("/metadata/:key", sessionController.GetMetadataValues)
+		sessionRoutes.GET("/:id", sessionController.GetSession)
 		sessionRoutes.POST("/:name", sessionController.CreateSession)
+		sessionRoutes.PATCH("/:id", sessionController.RenameSession)
+		sessionRoutes.PATCH("/:id/status", sessionController.UpdateSessionStatus)
+		sessionRoutes.PATCH("/:id/annotations", sessionController.UpdateAnnotations)
+		sessionRoutes.GET("/:id/history", sessionController.GetStatusHistory)
 		sessionRoutes.DELETE("/:id", sessionController.DeleteSession)
 	}
 }
@@ -2,16 +2,49 @@ package session
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
 	"time"
 
+	"aigendrug.com/aigendrug-cid-2025-server/database"
+	validator "github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var ErrSessionNotFound = errors.New("session not found")
+var ErrInvalidTransition = errors.New("invalid session status transition")
+var ErrSessionReadOnly = errors.New("session is archived or closed")
+var ErrInvalidCursor = errors.New("invalid session cursor")
+var ErrInvalidMetadata = errors.New("metadata filter must be a JSON object")
+
+const sessionColumns = `id, name, status, tool_status, assigned_tool_id, created_at, updated_at, status_changed_at, deleted_at, source_session_id, template_id, tags, notes, metadata, title, summary, summarized_at, summarized_message_count`
+
+const defaultSessionPageSize = 50
+
+// purgeBatchSize bounds how many sessions one purge transaction removes
+const purgeBatchSize = 100
+
 type SessionService interface {
-	ReadAllSessions(rctx context.Context) ([]*Session, error)
+	ReadSessions(rctx context.Context, query *SessionQuery) (*SessionPage, error)
+	ReadSession(rctx context.Context, id uuid.UUID) (*Session, error)
 	CreateSession(rctx context.Context, name string) (*Session, error)
+	RenameSession(rctx context.Context, id uuid.UUID, dto *RenameSessionDTO) (*Session, error)
+	UpdateSessionStatus(rctx context.Context, id uuid.UUID, dto *UpdateSessionStatusDTO) (*Session, error)
+	ReadStatusHistory(rctx context.Context, id uuid.UUID) ([]*SessionStatusTransition, error)
 	DeleteSession(rctx context.Context, id uuid.UUID) error
+	ReadDeletedSessions(rctx context.Context) ([]*Session, error)
+	RestoreSession(rctx context.Context, id uuid.UUID) (*Session, error)
+	UpdateAnnotations(rctx context.Context, id uuid.UUID, dto *UpdateAnnotationsDTO) (*Session, error)
+	ReadTags(rctx context.Context) ([]*TagCount, error)
+	ReadMetadataKeys(rctx context.Context) ([]*MetadataKeyCount, error)
+	ReadMetadataValues(rctx context.Context, key string) ([]*MetadataValueCount, error)
+	PurgeDeletedSessions(rctx context.Context, retention time.Duration) ([]uuid.UUID, error)
 }
 
 type sessionService struct {
@@ -23,63 +56,593 @@ func NewSessionService(c context.Context, db *pgxpool.Pool) SessionService {
 	return &sessionService{ctx: c, db: db}
 }
 
-func (s *sessionService) ReadAllSessions(rctx context.Context) ([]*Session, error) {
-	rows, err := s.db.Query(rctx, `
-        SELECT id, name, status, tool_status, assigned_tool_id, created_at
+// AcceptsMessages reports whether new chat or tool messages may be added to a session in status.
+func AcceptsMessages(status string) bool {
+	return status != SessionStatusArchived && status != SessionStatusClosed
+}
+
+// CanTransition reports whether a session may change from one status to another.
+func CanTransition(from string, to string) bool {
+	return slices.Contains(sessionTransitions[from], to)
+}
+
+// ReadSessions returns one page of the sessions matching query, newest first unless asked otherwise.
+// Pages are read by keyset on the sort time and the ID, so they stay stable while sessions are added.
+func (s *sessionService) ReadSessions(rctx context.Context, query *SessionQuery) (*SessionPage, error) {
+	validate := validator.New()
+	if err := validate.Struct(query); err != nil {
+		return nil, fmt.Errorf("session query validation failed: %w", err)
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultSessionPageSize
+	}
+	sort := query.Sort
+	if sort == "" {
+		sort = SessionSortCreatedAt
+	}
+	descending := query.Order != "asc"
+
+	conditions := []string{"deleted_at IS NULL"}
+	args := []interface{}{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(query.Status) > 0 {
+		conditions = append(conditions, "status = ANY("+arg(query.Status)+")")
+	}
+	if query.ToolID != "" {
+		conditions = append(conditions, "assigned_tool_id = "+arg(query.ToolID)+"::uuid")
+	}
+	if query.From != nil {
+		conditions = append(conditions, sort+" >= "+arg(*query.From))
+	}
+	if query.To != nil {
+		conditions = append(conditions, sort+" < "+arg(*query.To))
+	}
+	if len(query.Tag) > 0 {
+		conditions = append(conditions, "tags @> "+arg(query.Tag)+"::text[]")
+	}
+	if query.Metadata != "" {
+		var metadata map[string]any
+		if err := json.Unmarshal([]byte(query.Metadata), &metadata); err != nil || metadata == nil {
+			return nil, ErrInvalidMetadata
+		}
+		conditions = append(conditions, "metadata @> "+arg(metadata)+"::jsonb")
+	}
+	if search := strings.TrimSpace(query.Query); search != "" {
+		tsquery := "websearch_to_tsquery('english', " + arg(search) + ")"
+		conditions = append(conditions, `(search_vector @@ `+tsquery+` OR EXISTS (
+            SELECT 1 FROM chat_messages m WHERE m.session_id = sessions.id AND m.search_vector @@ `+tsquery+`
+        ))`)
+	}
+
+	comparison, direction := ">", "ASC"
+	if descending {
+		comparison, direction = "<", "DESC"
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeSessionCursor(query.Cursor, sort, descending)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "("+sort+", id) "+comparison+" ("+arg(cursor.Time)+", "+arg(cursor.ID)+")")
+	}
+
+	// One extra row tells whether there is a next page
+	sessions, err := s.querySessions(rctx, `
+        SELECT `+sessionColumns+`
         FROM sessions
+        WHERE `+strings.Join(conditions, " AND ")+`
+        ORDER BY `+sort+` `+direction+`, id `+direction+`
+        LIMIT `+arg(limit+1), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &SessionPage{Sessions: sessions}
+	if len(sessions) > limit {
+		page.Sessions = sessions[:limit]
+		last := page.Sessions[limit-1]
+		page.NextCursor, err = encodeSessionCursor(last, sort, descending)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func (s *sessionService) ReadSession(rctx context.Context, id uuid.UUID) (*Session, error) {
+	return readSession(rctx, s.db, id, "")
+}
+
+func (s *sessionService) CreateSession(rctx context.Context, name string) (*Session, error) {
+	createdAt := time.Now()
+	session := &Session{
+		ID:              uuid.New(),
+		Name:            name,
+		Status:          SessionStatusActive,
+		ToolStatus:      nil,
+		AssignedToolID:  nil,
+		CreatedAt:       createdAt,
+		UpdatedAt:       &createdAt,
+		StatusChangedAt: &createdAt,
+	}
+
+	err := database.WithTx(rctx, s.db, func(tx pgx.Tx) error {
+		return InsertSession(rctx, tx, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *sessionService) RenameSession(rctx context.Context, id uuid.UUID, dto *RenameSessionDTO) (*Session, error) {
+	dto.Name = strings.TrimSpace(dto.Name)
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("session validation failed: %w", err)
+	}
+
+	session, err := scanSession(s.db.QueryRow(rctx, `
+        UPDATE sessions SET name = $2, updated_at = $3
+        WHERE id = $1 AND deleted_at IS NULL
+        RETURNING `+sessionColumns, id, dto.Name, time.Now()))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	return session, err
+}
+
+// UpdateAnnotations changes the tags, notes and metadata of a session. Tags are trimmed, deduplicated and sorted.
+func (s *sessionService) UpdateAnnotations(rctx context.Context, id uuid.UUID, dto *UpdateAnnotationsDTO) (*Session, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("session validation failed: %w", err)
+	}
+
+	var tags []string
+	if dto.Tags != nil {
+		tags = normalizeTags(*dto.Tags)
+	}
+	setMetadata := map[string]any{}
+	removeMetadata := []string{}
+	for key, value := range dto.Metadata {
+		if value == nil {
+			removeMetadata = append(removeMetadata, key)
+		} else {
+			setMetadata[key] = value
+		}
+	}
+
+	session, err := scanSession(s.db.QueryRow(rctx, `
+        UPDATE sessions
+        SET tags = COALESCE($2::text[], tags),
+            notes = COALESCE($3, notes),
+            metadata = (metadata || $4::jsonb) - $5::text[],
+            updated_at = $6
+        WHERE id = $1 AND deleted_at IS NULL
+        RETURNING `+sessionColumns,
+		id, tags, dto.Notes, setMetadata, removeMetadata, time.Now(),
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	return session, err
+}
+
+// ReadTags lists the tags in use, most used first.
+func (s *sessionService) ReadTags(rctx context.Context) ([]*TagCount, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT tag, COUNT(*)
+        FROM sessions, unnest(tags) AS tag
+        WHERE deleted_at IS NULL
+        GROUP BY tag
+        ORDER BY COUNT(*) DESC, tag ASC
     `)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var sessions []*Session
+	tags := []*TagCount{}
 	for rows.Next() {
-		var session Session
-		err := rows.Scan(
-			&session.ID,
-			&session.Name,
-			&session.Status,
-			&session.ToolStatus,
-			&session.AssignedToolID,
-			&session.CreatedAt,
-		)
-		if err != nil {
+		var tag TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
 			return nil, err
 		}
-		sessions = append(sessions, &session)
+		tags = append(tags, &tag)
 	}
+	return tags, rows.Err()
+}
 
-	if len(sessions) == 0 {
-		return []*Session{}, nil
+// ReadMetadataKeys lists the metadata keys in use, most used first.
+func (s *sessionService) ReadMetadataKeys(rctx context.Context) ([]*MetadataKeyCount, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT key, COUNT(*)
+        FROM sessions, jsonb_object_keys(metadata) AS key
+        WHERE deleted_at IS NULL
+        GROUP BY key
+        ORDER BY COUNT(*) DESC, key ASC
+    `)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return sessions, nil
+	keys := []*MetadataKeyCount{}
+	for rows.Next() {
+		var key MetadataKeyCount
+		if err := rows.Scan(&key.Key, &key.Count); err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+	return keys, rows.Err()
 }
 
-func (s *sessionService) CreateSession(rctx context.Context, name string) (*Session, error) {
-	newUUID := uuid.New()
-	createdAt := time.Now()
+// ReadMetadataValues lists the values a metadata key has across sessions, most used first.
+func (s *sessionService) ReadMetadataValues(rctx context.Context, key string) ([]*MetadataValueCount, error) {
+	rows, err := s.db.Query(rctx, `
+        SELECT metadata -> $1, COUNT(*)
+        FROM sessions
+        WHERE deleted_at IS NULL AND metadata ? $1
+        GROUP BY 1
+        ORDER BY COUNT(*) DESC
+    `, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	_, err := s.db.Exec(rctx, `
-        INSERT INTO sessions (id, name, status, created_at)
-        VALUES ($1, $2, $3, $4)
-    `, newUUID, name, SessionStatusActive, createdAt)
+	values := []*MetadataValueCount{}
+	for rows.Next() {
+		var value MetadataValueCount
+		if err := rows.Scan(&value.Value, &value.Count); err != nil {
+			return nil, err
+		}
+		values = append(values, &value)
+	}
+	return values, rows.Err()
+}
+
+// UpdateSessionStatus moves the session along the status state machine and records the transition.
+// Setting the current status again is a no-op.
+func (s *sessionService) UpdateSessionStatus(rctx context.Context, id uuid.UUID, dto *UpdateSessionStatusDTO) (*Session, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("session validation failed: %w", err)
+	}
+
+	return database.WithTxResult(rctx, s.db, func(tx pgx.Tx) (*Session, error) {
+		session, err := readSession(rctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return nil, err
+		}
+		if session.Status == dto.Status {
+			return session, nil
+		}
+		if !CanTransition(session.Status, dto.Status) {
+			return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, session.Status, dto.Status)
+		}
+
+		now := time.Now()
+		updated, err := scanSession(tx.QueryRow(rctx, `
+            UPDATE sessions SET status = $2, status_changed_at = $3, updated_at = $3
+            WHERE id = $1
+            RETURNING `+sessionColumns, id, dto.Status, now))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := recordTransition(rctx, tx, id, &session.Status, dto.Status, now); err != nil {
+			return nil, err
+		}
+		return updated, nil
+	})
+}
+
+func (s *sessionService) ReadStatusHistory(rctx context.Context, id uuid.UUID) ([]*SessionStatusTransition, error) {
+	if _, err := s.ReadSession(rctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(rctx, `
+        SELECT id, session_id, from_status, to_status, changed_at
+        FROM session_status_history
+        WHERE session_id = $1
+        ORDER BY changed_at
+    `, id)
 	if err != nil {
 		return nil, err
 	}
-	toolStatus := ""
-	return &Session{
-		ID:             newUUID,
-		Name:           name,
-		Status:         "active",
-		ToolStatus:     &toolStatus,
-		AssignedToolID: nil,
-		CreatedAt:      time.Now(),
-	}, nil
+	defer rows.Close()
+
+	transitions := []*SessionStatusTransition{}
+	for rows.Next() {
+		var transition SessionStatusTransition
+		if err := rows.Scan(
+			&transition.ID,
+			&transition.SessionID,
+			&transition.FromStatus,
+			&transition.ToStatus,
+			&transition.ChangedAt,
+		); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, &transition)
+	}
+	return transitions, rows.Err()
 }
 
+// DeleteSession moves the session to the trash. Its messages are kept until it is purged.
 func (s *sessionService) DeleteSession(rctx context.Context, id uuid.UUID) error {
-	_, err := s.db.Exec(rctx, "DELETE FROM sessions WHERE id = $1", id)
+	tag, err := s.db.Exec(rctx, `
+        UPDATE sessions SET deleted_at = $2
+        WHERE id = $1 AND deleted_at IS NULL
+    `, id, time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *sessionService) ReadDeletedSessions(rctx context.Context) ([]*Session, error) {
+	return s.querySessions(rctx, "SELECT "+sessionColumns+" FROM sessions WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+}
+
+// RestoreSession takes a session out of the trash, with the status it had when it was deleted.
+func (s *sessionService) RestoreSession(rctx context.Context, id uuid.UUID) (*Session, error) {
+	session, err := scanSession(s.db.QueryRow(rctx, `
+        UPDATE sessions SET deleted_at = NULL, updated_at = $2
+        WHERE id = $1 AND deleted_at IS NOT NULL
+        RETURNING `+sessionColumns, id, time.Now()))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w in the trash", ErrSessionNotFound)
+	}
+	return session, err
+}
+
+// PurgeDeletedSessions permanently removes the sessions that were deleted more than retention ago,
+// together with everything that belongs to them, and returns their IDs. Each batch of sessions is
+// removed in one transaction, so a session is never left half purged.
+func (s *sessionService) PurgeDeletedSessions(rctx context.Context, retention time.Duration) ([]uuid.UUID, error) {
+	deletedBefore := time.Now().Add(-retention)
+	purged := []uuid.UUID{}
+	for {
+		batch, err := database.WithTxResult(rctx, s.db, func(tx pgx.Tx) ([]uuid.UUID, error) {
+			return purgeSessions(rctx, tx, deletedBefore)
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged = append(purged, batch...)
+		if len(batch) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+func (s *sessionService) querySessions(rctx context.Context, query string, args ...interface{}) ([]*Session, error) {
+	rows, err := s.db.Query(rctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// purgeSessions deletes one batch of sessions deleted before deletedBefore. Sessions that are being
+// purged by another instance are skipped.
+func purgeSessions(rctx context.Context, tx pgx.Tx, deletedBefore time.Time) ([]uuid.UUID, error) {
+	rows, err := tx.Query(rctx, `
+        SELECT id FROM sessions
+        WHERE deleted_at < $1
+        ORDER BY deleted_at
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED
+    `, deletedBefore, purgeBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil || len(ids) == 0 {
+		return ids, err
+	}
+
+	// Evaluation cases outlive the selections they were labelled from
+	_, err = tx.Exec(rctx, `
+        UPDATE tool_selection_cases SET selection_id = NULL
+        WHERE selection_id IN (SELECT id FROM tool_selections WHERE session_id = ANY($1))
+    `, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Children before parents; participants, interrupted operations and status history cascade
+	for _, table := range []string{
+		"chat_message_feedback",
+		"tool_selections",
+		"chat_messages",
+		"chat_branches",
+		"tool_messages",
+		"sessions",
+	} {
+		column := "session_id"
+		if table == "sessions" {
+			column = "id"
+		}
+		if _, err := tx.Exec(rctx, "DELETE FROM "+table+" WHERE "+column+" = ANY($1)", ids); err != nil {
+			return nil, fmt.Errorf("failed to purge %s: %w", table, err)
+		}
+	}
+	return ids, nil
+}
+
+// RunSessionPurge purges sessions deleted more than retention ago every interval until ctx is cancelled.
+func RunSessionPurge(ctx context.Context, sessionService SessionService, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := sessionService.PurgeDeletedSessions(ctx, retention)
+		if err != nil {
+			log.Println("Session purge failed:", err)
+		}
+		if len(purged) > 0 {
+			log.Printf("Session purge removed %d sessions", len(purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LockWritableSession locks the session row for the rest of tx and fails unless it accepts new messages.
+// Chat and tool messages are added under this lock, which also keeps their seq order equal to commit order.
+func LockWritableSession(rctx context.Context, tx database.DbExecutor, id uuid.UUID) (*Session, error) {
+	session, err := readSession(rctx, tx, id, "FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+	if !AcceptsMessages(session.Status) {
+		return nil, fmt.Errorf("%w: %s", ErrSessionReadOnly, session.Status)
+	}
+	return session, nil
+}
+
+// TouchSession records activity in the session, such as a new message, so that it sorts as recently updated.
+func TouchSession(rctx context.Context, db database.DbExecutor, id uuid.UUID, at time.Time) error {
+	_, err := db.Exec(rctx, "UPDATE sessions SET updated_at = $2 WHERE id = $1", id, at)
+	return err
+}
+
+// ShareLockSession reads the session and share-locks its row for the rest of tx. New messages and
+// changes to the session wait until tx ends, so the session can be copied consistently.
+func ShareLockSession(rctx context.Context, tx database.DbExecutor, id uuid.UUID) (*Session, error) {
+	return readSession(rctx, tx, id, "FOR SHARE")
+}
+
+// InsertSession saves a new session, which may already have a tool assigned, and starts its status
+// history at its current status.
+func InsertSession(rctx context.Context, db database.DbExecutor, session *Session) error {
+	var toolStatusChangedAt *time.Time
+	if session.ToolStatus != nil {
+		toolStatusChangedAt = &session.CreatedAt
+	}
+
+	if session.Tags == nil {
+		session.Tags = []string{}
+	}
+	if session.Metadata == nil {
+		session.Metadata = map[string]any{}
+	}
+
+	_, err := db.Exec(rctx, `
+        INSERT INTO sessions
+            (id, name, status, tool_status, assigned_tool_id, created_at, updated_at, status_changed_at, source_session_id, template_id,
+             tags, notes, metadata, tool_status_changed_at)
+        VALUES
+            ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+    `,
+		session.ID,
+		session.Name,
+		session.Status,
+		session.ToolStatus,
+		session.AssignedToolID,
+		session.CreatedAt,
+		session.UpdatedAt,
+		session.StatusChangedAt,
+		session.SourceSessionID,
+		session.TemplateID,
+		session.Tags,
+		session.Notes,
+		session.Metadata,
+		toolStatusChangedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return recordTransition(rctx, db, session.ID, nil, session.Status, session.CreatedAt)
+}
+
+// readSession reads a session that is not in the trash. lock is an optional row locking clause.
+func readSession(rctx context.Context, db database.DbExecutor, id uuid.UUID, lock string) (*Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE id = $1 AND deleted_at IS NULL"
+	if lock != "" {
+		query += " " + lock
+	}
+
+	session, err := scanSession(db.QueryRow(rctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	return session, err
+}
+
+func recordTransition(rctx context.Context, db database.DbExecutor, sessionID uuid.UUID, from *string, to string, changedAt time.Time) error {
+	_, err := db.Exec(rctx, `
+        INSERT INTO session_status_history (id, session_id, from_status, to_status, changed_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `, uuid.New(), sessionID, from, to, changedAt)
 	return err
 }
+
+func scanSession(row pgx.Row) (*Session, error) {
+	var session Session
+	err := row.Scan(
+		&session.ID,
+		&session.Name,
+		&session.Status,
+		&session.ToolStatus,
+		&session.AssignedToolID,
+		&session.CreatedAt,
+		&session.UpdatedAt,
+		&session.StatusChangedAt,
+		&session.DeletedAt,
+		&session.SourceSessionID,
+		&session.TemplateID,
+		&session.Tags,
+		&session.Notes,
+		&session.Metadata,
+		&session.Title,
+		&session.Summary,
+		&session.SummarizedAt,
+		&session.SummarizedMessageCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	slices.Sort(normalized)
+	return normalized
+}
@@ -0,0 +1,82 @@
+package session
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want bool
+	}{
+		{from: SessionStatusActive, to: SessionStatusPaused, want: true},
+		{from: SessionStatusActive, to: SessionStatusArchived, want: true},
+		{from: SessionStatusActive, to: SessionStatusClosed, want: true},
+		{from: SessionStatusActive, to: SessionStatusActive, want: false},
+		{from: SessionStatusPaused, to: SessionStatusActive, want: true},
+		{from: SessionStatusPaused, to: SessionStatusArchived, want: true},
+		{from: SessionStatusPaused, to: SessionStatusClosed, want: true},
+		{from: SessionStatusArchived, to: SessionStatusActive, want: true},
+		{from: SessionStatusArchived, to: SessionStatusClosed, want: true},
+		{from: SessionStatusArchived, to: SessionStatusPaused, want: false},
+		{from: SessionStatusClosed, to: SessionStatusArchived, want: true},
+		{from: SessionStatusClosed, to: SessionStatusActive, want: false},
+		{from: SessionStatusClosed, to: SessionStatusPaused, want: false},
+		{from: "unknown", to: SessionStatusActive, want: false},
+		{from: SessionStatusActive, to: "unknown", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptsMessages(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{status: SessionStatusActive, want: true},
+		{status: SessionStatusPaused, want: true},
+		{status: SessionStatusArchived, want: false},
+		{status: SessionStatusClosed, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := AcceptsMessages(tt.status); got != tt.want {
+				t.Errorf("AcceptsMessages(%q) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{name: "no tags", tags: nil, want: []string{}},
+		{name: "sorted", tags: []string{"kinase", "egfr"}, want: []string{"egfr", "kinase"}},
+		{name: "trimmed", tags: []string{"  egfr ", "\tkinase"}, want: []string{"egfr", "kinase"}},
+		{name: "duplicates after trimming", tags: []string{"egfr", " egfr", "egfr "}, want: []string{"egfr"}},
+		{name: "blank tags dropped", tags: []string{"", "  ", "egfr"}, want: []string{"egfr"}},
+		{name: "case is kept", tags: []string{"EGFR", "egfr"}, want: []string{"EGFR", "egfr"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeTags(tt.tags)
+			if got == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("normalizeTags(%q) = %q, want %q", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package sessiontemplate
+
+import (
+	"errors"
+	"net/http"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
+	"github.com/gin-gonic/gin"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type SessionTemplateController struct {
+	sessionTemplateService SessionTemplateService
+}
+
+func NewSessionTemplateController(sessionTemplateService SessionTemplateService) *SessionTemplateController {
+	return &SessionTemplateController{sessionTemplateService: sessionTemplateService}
+}
+
+func (tc *SessionTemplateController) GetTemplates(c *gin.Context) {
+	templates, err := tc.sessionTemplateService.ReadAllTemplates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+func (tc *SessionTemplateController) GetTemplate(c *gin.Context) {
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionTemplate, err := tc.sessionTemplateService.ReadTemplate(c.Request.Context(), templateID)
+	if err != nil {
+		c.JSON(sessionTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessionTemplate)
+}
+
+func (tc *SessionTemplateController) CreateTemplate(c *gin.Context) {
+	var dto SessionTemplateDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionTemplate, err := tc.sessionTemplateService.CreateTemplate(c.Request.Context(), &dto)
+	if err != nil {
+		c.JSON(sessionTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, sessionTemplate)
+}
+
+func (tc *SessionTemplateController) UpdateTemplate(c *gin.Context) {
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto SessionTemplateDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionTemplate, err := tc.sessionTemplateService.UpdateTemplate(c.Request.Context(), templateID, &dto)
+	if err != nil {
+		c.JSON(sessionTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessionTemplate)
+}
+
+func (tc *SessionTemplateController) DeleteTemplate(c *gin.Context) {
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tc.sessionTemplateService.DeleteTemplate(c.Request.Context(), templateID); err != nil {
+		c.JSON(sessionTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusNoContent, gin.H{})
+}
+
+// CreateSession creates a session from the template. The body is optional.
+func (tc *SessionTemplateController) CreateSession(c *gin.Context) {
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto CreateTemplateSessionDTO
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&dto); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	templateSession, err := tc.sessionTemplateService.CreateSession(c.Request.Context(), templateID, &dto)
+	if err != nil {
+		c.JSON(sessionTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, templateSession)
+}
+
+func sessionTemplateErrorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, ErrTemplateNotFound), errors.Is(err, tool.ErrToolNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidParameter), errors.As(err, &validationErrors):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,50 @@
+package sessiontemplate
+
+import (
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/chat"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
+	"github.com/google/uuid"
+)
+
+// SessionTemplate is the common start of a recurring workflow: the tool to assign, the messages
+// a session opens with and default values for the tool's request interface.
+type SessionTemplate struct {
+	ID                uuid.UUID                     `json:"id"`
+	Name              string                        `json:"name"`
+	Description       string                        `json:"description"`
+	ToolID            *uuid.UUID                    `json:"tool_id"`
+	SeedMessages      []SeedMessage                 `json:"seed_messages"`
+	DefaultParameters []tool.ToolInteractionElement `json:"default_parameters"`
+	CreatedAt         time.Time                     `json:"created_at"`
+	UpdatedAt         time.Time                     `json:"updated_at"`
+}
+
+type SeedMessage struct {
+	Role    string `json:"role" validate:"required,oneof=system user"`
+	Message string `json:"message" validate:"required"`
+}
+
+// SessionTemplateDTO creates a template or replaces all of its fields.
+type SessionTemplateDTO struct {
+	Name              string                        `json:"name" validate:"required,max=200"`
+	Description       string                        `json:"description"`
+	ToolID            *uuid.UUID                    `json:"tool_id"`
+	SeedMessages      []SeedMessage                 `json:"seed_messages" validate:"dive"`
+	DefaultParameters []tool.ToolInteractionElement `json:"default_parameters" validate:"dive"`
+}
+
+// CreateTemplateSessionDTO names the new session; by default it is named after the template.
+type CreateTemplateSessionDTO struct {
+	Name string `json:"name" validate:"max=200"`
+}
+
+// TemplateSession is a session created from a template, with its seeded messages and the
+// template's default tool parameters for clients to prefill.
+type TemplateSession struct {
+	Session        *session.Session              `json:"session"`
+	Messages       []*chat.ChatMessage           `json:"messages"`
+	ToolParameters []tool.ToolInteractionElement `json:"tool_parameters"`
+}
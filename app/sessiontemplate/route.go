@@ -0,0 +1,23 @@
+package sessiontemplate
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupSessionTemplateRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool) {
+	sessionTemplateService := NewSessionTemplateService(c, db)
+	sessionTemplateController := NewSessionTemplateController(sessionTemplateService)
+
+	sessionTemplateRoutes := router.Group("/v1/session-template")
+	{
+		sessionTemplateRoutes.GET("", sessionTemplateController.GetTemplates)
+		sessionTemplateRoutes.GET("/:id", sessionTemplateController.GetTemplate)
+		sessionTemplateRoutes.POST("", sessionTemplateController.CreateTemplate)
+		sessionTemplateRoutes.PUT("/:id", sessionTemplateController.UpdateTemplate)
+		sessionTemplateRoutes.DELETE("/:id", sessionTemplateController.DeleteTemplate)
+		sessionTemplateRoutes.POST("/:id/session", sessionTemplateController.CreateSession)
+	}
+}
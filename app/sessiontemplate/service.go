@@ -0,0 +1,300 @@
+package sessiontemplate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/assignment"
+	"aigendrug.com/aigendrug-cid-2025-server/app/chat"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
+	"aigendrug.com/aigendrug-cid-2025-server/database"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrTemplateNotFound = errors.New("session template not found")
+var ErrInvalidParameter = errors.New("invalid default tool parameter")
+
+const sessionTemplateColumns = `id, name, description, tool_id, seed_messages, default_parameters, created_at, updated_at`
+
+type SessionTemplateService interface {
+	ReadAllTemplates(rctx context.Context) ([]*SessionTemplate, error)
+	ReadTemplate(rctx context.Context, id uuid.UUID) (*SessionTemplate, error)
+	CreateTemplate(rctx context.Context, dto *SessionTemplateDTO) (*SessionTemplate, error)
+	UpdateTemplate(rctx context.Context, id uuid.UUID, dto *SessionTemplateDTO) (*SessionTemplate, error)
+	DeleteTemplate(rctx context.Context, id uuid.UUID) error
+	CreateSession(rctx context.Context, id uuid.UUID, dto *CreateTemplateSessionDTO) (*TemplateSession, error)
+}
+
+type sessionTemplateService struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func NewSessionTemplateService(c context.Context, db *pgxpool.Pool) SessionTemplateService {
+	return &sessionTemplateService{ctx: c, db: db}
+}
+
+func (s *sessionTemplateService) ReadAllTemplates(rctx context.Context) ([]*SessionTemplate, error) {
+	rows, err := s.db.Query(rctx, "SELECT "+sessionTemplateColumns+" FROM session_templates ORDER BY name ASC, created_at ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*SessionTemplate{}
+	for rows.Next() {
+		sessionTemplate, err := scanSessionTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, sessionTemplate)
+	}
+	return templates, rows.Err()
+}
+
+func (s *sessionTemplateService) ReadTemplate(rctx context.Context, id uuid.UUID) (*SessionTemplate, error) {
+	sessionTemplate, err := scanSessionTemplate(s.db.QueryRow(rctx, "SELECT "+sessionTemplateColumns+" FROM session_templates WHERE id = $1", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	return sessionTemplate, err
+}
+
+func (s *sessionTemplateService) CreateTemplate(rctx context.Context, dto *SessionTemplateDTO) (*SessionTemplate, error) {
+	if err := s.validateTemplate(rctx, dto); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessionTemplate := templateFromDTO(uuid.New(), dto, now)
+	sessionTemplate.CreatedAt = now
+
+	seedMessages, defaultParameters, err := marshalTemplate(sessionTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(rctx, `
+        INSERT INTO session_templates (`+sessionTemplateColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `,
+		sessionTemplate.ID,
+		sessionTemplate.Name,
+		sessionTemplate.Description,
+		sessionTemplate.ToolID,
+		seedMessages,
+		defaultParameters,
+		sessionTemplate.CreatedAt,
+		sessionTemplate.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sessionTemplate, nil
+}
+
+// UpdateTemplate replaces the template's fields. Sessions created from it earlier are not affected.
+func (s *sessionTemplateService) UpdateTemplate(rctx context.Context, id uuid.UUID, dto *SessionTemplateDTO) (*SessionTemplate, error) {
+	if err := s.validateTemplate(rctx, dto); err != nil {
+		return nil, err
+	}
+
+	seedMessages, defaultParameters, err := marshalTemplate(templateFromDTO(id, dto, time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	sessionTemplate, err := scanSessionTemplate(s.db.QueryRow(rctx, `
+        UPDATE session_templates
+        SET name = $2, description = $3, tool_id = $4, seed_messages = $5, default_parameters = $6, updated_at = $7
+        WHERE id = $1
+        RETURNING `+sessionTemplateColumns,
+		id, dto.Name, dto.Description, dto.ToolID, seedMessages, defaultParameters, time.Now(),
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	return sessionTemplate, err
+}
+
+func (s *sessionTemplateService) DeleteTemplate(rctx context.Context, id uuid.UUID) error {
+	result, err := s.db.Exec(rctx, "DELETE FROM session_templates WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// CreateSession creates a session from the template with its tool already confirmed and its seed
+// messages on the main branch. Seed messages are history; they get no assistant answer.
+func (s *sessionTemplateService) CreateSession(rctx context.Context, id uuid.UUID, dto *CreateTemplateSessionDTO) (*TemplateSession, error) {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return nil, fmt.Errorf("session validation failed: %w", err)
+	}
+
+	sessionTemplate, err := s.ReadTemplate(rctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// The tool may have been deleted since the template was saved
+	if sessionTemplate.ToolID != nil {
+		if _, err := tool.NewToolService(rctx, s.db).ReadTool(rctx, *sessionTemplate.ToolID); err != nil {
+			return nil, err
+		}
+	}
+
+	createdAt := time.Now()
+	newSession := &session.Session{
+		ID:              uuid.New(),
+		Name:            sessionTemplate.Name,
+		Status:          session.SessionStatusActive,
+		AssignedToolID:  sessionTemplate.ToolID,
+		CreatedAt:       createdAt,
+		UpdatedAt:       &createdAt,
+		StatusChangedAt: &createdAt,
+		TemplateID:      &sessionTemplate.ID,
+	}
+	if dto.Name != "" {
+		newSession.Name = dto.Name
+	}
+	if sessionTemplate.ToolID != nil {
+		confirmed := assignment.ToolStatusConfirmed
+		newSession.ToolStatus = &confirmed
+	}
+
+	messages, err := database.WithTxResult(rctx, s.db, func(tx pgx.Tx) ([]*chat.ChatMessage, error) {
+		if err := session.InsertSession(rctx, tx, newSession); err != nil {
+			return nil, err
+		}
+
+		messages := make([]*chat.ChatMessage, 0, len(sessionTemplate.SeedMessages))
+		for _, seed := range sessionTemplate.SeedMessages {
+			chatMessage, err := chat.AppendChatMessage(rctx, tx, &chat.CreateChatMessageDTO{
+				SessionID:   newSession.ID,
+				Role:        seed.Role,
+				Message:     seed.Message,
+				MessageType: chat.ChatMessageTypeNormal,
+			})
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, chatMessage)
+		}
+		return messages, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(messages) > 0 {
+		newSession.UpdatedAt = &messages[len(messages)-1].CreatedAt
+	}
+	return &TemplateSession{
+		Session:        newSession,
+		Messages:       messages,
+		ToolParameters: sessionTemplate.DefaultParameters,
+	}, nil
+}
+
+// validateTemplate checks the template and that its default parameters belong to its tool's request interface.
+func (s *sessionTemplateService) validateTemplate(rctx context.Context, dto *SessionTemplateDTO) error {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return fmt.Errorf("session template validation failed: %w", err)
+	}
+
+	if dto.ToolID == nil {
+		if len(dto.DefaultParameters) > 0 {
+			return fmt.Errorf("%w: default parameters need a tool", ErrInvalidParameter)
+		}
+		return nil
+	}
+
+	templateTool, err := tool.NewToolService(rctx, s.db).ReadTool(rctx, *dto.ToolID)
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]bool{}
+	for _, element := range templateTool.ProviderInterface.RequestInterface {
+		keys[element.Key] = true
+	}
+	seen := map[string]bool{}
+	for _, parameter := range dto.DefaultParameters {
+		if !keys[parameter.Interface_id] {
+			return fmt.Errorf("%w: %s is not in the request interface of %s", ErrInvalidParameter, parameter.Interface_id, templateTool.Name)
+		}
+		if seen[parameter.Interface_id] {
+			return fmt.Errorf("%w: %s is set twice", ErrInvalidParameter, parameter.Interface_id)
+		}
+		seen[parameter.Interface_id] = true
+	}
+	return nil
+}
+
+func templateFromDTO(id uuid.UUID, dto *SessionTemplateDTO, updatedAt time.Time) *SessionTemplate {
+	sessionTemplate := &SessionTemplate{
+		ID:                id,
+		Name:              dto.Name,
+		Description:       dto.Description,
+		ToolID:            dto.ToolID,
+		SeedMessages:      dto.SeedMessages,
+		DefaultParameters: dto.DefaultParameters,
+		UpdatedAt:         updatedAt,
+	}
+	if sessionTemplate.SeedMessages == nil {
+		sessionTemplate.SeedMessages = []SeedMessage{}
+	}
+	if sessionTemplate.DefaultParameters == nil {
+		sessionTemplate.DefaultParameters = []tool.ToolInteractionElement{}
+	}
+	return sessionTemplate
+}
+
+func marshalTemplate(sessionTemplate *SessionTemplate) (string, string, error) {
+	seedMessages, err := json.Marshal(sessionTemplate.SeedMessages)
+	if err != nil {
+		return "", "", err
+	}
+	defaultParameters, err := json.Marshal(sessionTemplate.DefaultParameters)
+	if err != nil {
+		return "", "", err
+	}
+	return string(seedMessages), string(defaultParameters), nil
+}
+
+func scanSessionTemplate(row pgx.Row) (*SessionTemplate, error) {
+	var sessionTemplate SessionTemplate
+	var seedMessages, defaultParameters string
+	err := row.Scan(
+		&sessionTemplate.ID,
+		&sessionTemplate.Name,
+		&sessionTemplate.Description,
+		&sessionTemplate.ToolID,
+		&seedMessages,
+		&defaultParameters,
+		&sessionTemplate.CreatedAt,
+		&sessionTemplate.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(seedMessages), &sessionTemplate.SeedMessages); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(defaultParameters), &sessionTemplate.DefaultParameters); err != nil {
+		return nil, err
+	}
+	return &sessionTemplate, nil
+}
@@ -0,0 +1,47 @@
+package summary
+
+import (
+	"errors"
+	"net/http"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SummaryController struct {
+	summaryService SummaryService
+	hub            *hub.Hub
+}
+
+func NewSummaryController(summaryService SummaryService, h *hub.Hub) *SummaryController {
+	return &SummaryController{summaryService: summaryService, hub: h}
+}
+
+// RefreshSummary generates the session's title and summary now instead of waiting for new messages.
+func (sc *SummaryController) RefreshSummary(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := sc.summaryService.SummarizeSession(c.Request.Context(), sessionID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, session.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	// No messages yet, or another server is storing a summary right now
+	if summary == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	Announce(sc.hub, summary)
+	c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,23 @@
+package summary
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionSummary is the generated title and summary of a session. It is also the payload of
+// "session-summary" envelopes.
+type SessionSummary struct {
+	SessionID    uuid.UUID `json:"session_id"`
+	Title        string    `json:"title"`
+	Summary      string    `json:"summary"`
+	MessageCount int       `json:"message_count"`
+	SummarizedAt time.Time `json:"summarized_at"`
+}
+
+// generatedSummary is the JSON object the model is asked to answer with.
+type generatedSummary struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
@@ -0,0 +1,19 @@
+package summary
+
+import (
+	"context"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func SetupSummaryRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool, chatHub *hub.Hub) {
+	summaryService := NewSummaryService(c, db)
+	summaryController := NewSummaryController(summaryService, chatHub)
+
+	summaryRoutes := router.Group("/v1/summary")
+	{
+		summaryRoutes.POST("/:sessionID", summaryController.RefreshSummary)
+	}
+}
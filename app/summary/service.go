@@ -0,0 +1,266 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"aigendrug.com/aigendrug-cid-2025-server/app/chat"
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/openai/openai-go"
+)
+
+// firstSummaryMessages is how many chat messages a session needs for its first title, so that
+// sessions get a useful title after the first exchange instead of after a full refresh interval
+const firstSummaryMessages = 2
+
+// summaryBatchSize bounds how many sessions one run summarizes
+const summaryBatchSize = 20
+
+// summaryContextMessages is how many of the latest messages are sent along with the previous summary
+const summaryContextMessages = 50
+
+// maxTitleLength keeps titles short enough for the session list
+const maxTitleLength = 80
+
+// A failed session is retried after summaryRetryDelay, doubled with every further failure up to maxSummaryRetryDelay
+const (
+	summaryRetryDelay    = time.Minute
+	maxSummaryRetryDelay = 24 * time.Hour
+)
+
+const summaryPrompt = `
+				You summarize lab sessions in which a scientist works with an assistant that selects and runs drug discovery tools.
+				Answer with a JSON object with two fields:
+				"title": a specific title of at most 8 words naming what the session is about, such as the target, compound series or analysis, without quotes.
+				"summary": 2 to 4 sentences on what was asked, which tools were used and what was found or is still open.
+				The previous summary, if any, covers earlier messages that may no longer be included; keep what still matters from it.
+			`
+
+type SummaryService interface {
+	SummarizeDueSessions(rctx context.Context, every int) ([]*SessionSummary, error)
+	SummarizeSession(rctx context.Context, sessionID uuid.UUID) (*SessionSummary, error)
+}
+
+type summaryService struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func NewSummaryService(c context.Context, db *pgxpool.Pool) SummaryService {
+	return &summaryService{ctx: c, db: db}
+}
+
+// SummarizeDueSessions refreshes the sessions that got every or more chat messages since their last
+// summary, least recently updated first. Sessions that fail are reported together and retried later
+// with a backoff, so they do not take the place of other due sessions in the next runs.
+func (s *summaryService) SummarizeDueSessions(rctx context.Context, every int) ([]*SessionSummary, error) {
+	// Sessions without new activity since their last summary cannot be due, which keeps the counts
+	// to recently updated sessions
+	rows, err := s.db.Query(rctx, `
+        SELECT id
+        FROM (
+            SELECT s.id, s.updated_at, s.title, s.summarized_message_count,
+                (SELECT COUNT(*) FROM chat_messages m WHERE m.session_id = s.id) AS message_count
+            FROM sessions s
+            WHERE s.deleted_at IS NULL
+                AND (s.summarized_at IS NULL OR s.updated_at > s.summarized_at)
+                AND (s.summary_retry_at IS NULL OR s.summary_retry_at <= $4)
+        ) candidates
+        WHERE message_count >= summarized_message_count + CASE WHEN title IS NULL THEN $1 ELSE $2 END
+        ORDER BY updated_at ASC
+        LIMIT $3
+    `, firstSummaryMessages, every, summaryBatchSize, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	sessionIDs := []uuid.UUID{}
+	for rows.Next() {
+		var sessionID uuid.UUID
+		if err := rows.Scan(&sessionID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	summaries := []*SessionSummary{}
+	var errs []error
+	for _, sessionID := range sessionIDs {
+		summary, err := s.SummarizeSession(rctx, sessionID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("session %s: %w", sessionID, err))
+			if err := s.postponeSession(rctx, sessionID); err != nil {
+				errs = append(errs, fmt.Errorf("session %s: %w", sessionID, err))
+			}
+			continue
+		}
+		if summary != nil {
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries, errors.Join(errs...)
+}
+
+// postponeSession schedules the next attempt for a session whose summary failed.
+func (s *summaryService) postponeSession(rctx context.Context, sessionID uuid.UUID) error {
+	_, err := s.db.Exec(rctx, `
+        UPDATE sessions
+        SET summary_failures = summary_failures + 1,
+            summary_retry_at = $2::timestamp + make_interval(secs => LEAST($3::float8 * POWER(2, summary_failures), $4::float8))
+        WHERE id = $1
+    `, sessionID, time.Now(), summaryRetryDelay.Seconds(), maxSummaryRetryDelay.Seconds())
+	return err
+}
+
+// SummarizeSession generates a new title and summary from the session's active branch and its previous
+// summary. It returns nil when there is nothing to summarize yet or another server summarized the session
+// at the same time.
+func (s *summaryService) SummarizeSession(rctx context.Context, sessionID uuid.UUID) (*SessionSummary, error) {
+	currentSession, err := session.NewSessionService(rctx, s.db).ReadSession(rctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Messages added from here on are counted towards the next summary
+	summarizedAt := time.Now()
+	var messageCount int
+	err = s.db.QueryRow(rctx, "SELECT COUNT(*) FROM chat_messages WHERE session_id = $1", sessionID).Scan(&messageCount)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := chat.NewChatService(rctx, s.db).ReadChatMessages(rctx, sessionID, &chat.ChatMessageQuery{
+		Limit:       summaryContextMessages,
+		MessageType: []int{chat.ChatMessageTypeNormal},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Without normal messages, e.g. only tool selections, the counted messages are skipped so that the
+	// session is not due again until it gets new ones
+	if len(page.Messages) == 0 {
+		_, err := s.db.Exec(rctx, `
+            UPDATE sessions
+            SET summarized_at = $2, summarized_message_count = $3
+            WHERE id = $1 AND deleted_at IS NULL AND summarized_message_count = $4
+        `, sessionID, summarizedAt, messageCount, currentSession.SummarizedMessageCount)
+		return nil, err
+	}
+
+	generated, err := generateSummary(rctx, currentSession, page.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the server that read the current count stores its summary
+	tag, err := s.db.Exec(rctx, `
+        UPDATE sessions
+        SET title = $2, summary = $3, summarized_at = $4, summarized_message_count = $5,
+            summary_failures = 0, summary_retry_at = NULL
+        WHERE id = $1 AND deleted_at IS NULL AND summarized_message_count = $6
+    `, sessionID, generated.Title, generated.Summary, summarizedAt, messageCount, currentSession.SummarizedMessageCount)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, nil
+	}
+
+	return &SessionSummary{
+		SessionID:    sessionID,
+		Title:        generated.Title,
+		Summary:      generated.Summary,
+		MessageCount: messageCount,
+		SummarizedAt: summarizedAt,
+	}, nil
+}
+
+func generateSummary(ctx context.Context, currentSession *session.Session, messages []*chat.ChatMessage) (*generatedSummary, error) {
+	var conversation strings.Builder
+	fmt.Fprintf(&conversation, "Session name given by the user: %s\n", currentSession.Name)
+	if currentSession.Summary != nil {
+		fmt.Fprintf(&conversation, "Previous summary: %s\n", *currentSession.Summary)
+	}
+	conversation.WriteString("\nLatest messages:\n")
+	for _, message := range messages {
+		fmt.Fprintf(&conversation, "%s: %s\n", message.Role, message.Message)
+	}
+
+	client := openai.NewClient()
+	chatCompletion, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(summaryPrompt),
+			openai.UserMessage(conversation.String()),
+		}),
+		Model: openai.F(openai.ChatModelGPT4o),
+		ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](openai.ResponseFormatJSONObjectParam{
+			Type: openai.F(openai.ResponseFormatJSONObjectTypeJSONObject),
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(chatCompletion.Choices) == 0 {
+		return nil, fmt.Errorf("AI response has no choices")
+	}
+
+	var generated generatedSummary
+	if err := json.Unmarshal([]byte(chatCompletion.Choices[0].Message.Content), &generated); err != nil {
+		return nil, fmt.Errorf("AI summary is not valid JSON: %w", err)
+	}
+	generated.Title = strings.Trim(strings.TrimSpace(generated.Title), `"`)
+	generated.Summary = strings.TrimSpace(generated.Summary)
+	if generated.Title == "" || generated.Summary == "" {
+		return nil, fmt.Errorf("AI summary is missing its title or summary")
+	}
+	if title := []rune(generated.Title); len(title) > maxTitleLength {
+		generated.Title = strings.TrimSpace(string(title[:maxTitleLength]))
+	}
+	return &generated, nil
+}
+
+// Announce sends the summary to the clients of its session as a "session-summary" envelope.
+func Announce(h *hub.Hub, summary *SessionSummary) {
+	envelope, err := hub.NewEnvelope(hub.EventSessionSummary, "", summary)
+	if err == nil {
+		err = h.Broadcast(summary.SessionID.String(), envelope)
+	}
+	if err != nil {
+		log.Println("Broadcast Error:", err)
+	}
+}
+
+// RunSessionSummaries refreshes the titles and summaries of sessions with every new chat messages,
+// checking every interval until ctx is cancelled, and announces them on the chat hub.
+func RunSessionSummaries(ctx context.Context, summaryService SummaryService, h *hub.Hub, every int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		summaries, err := summaryService.SummarizeDueSessions(ctx, every)
+		if err != nil {
+			log.Println("Session summary failed:", err)
+		}
+		for _, summary := range summaries {
+			Announce(h, summary)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
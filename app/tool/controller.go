@@ -1,18 +1,22 @@
 package tool
 
 import (
+	"errors"
 	"net/http"
 
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
 	"github.com/gin-gonic/gin"
+	validator "github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
 type ToolController struct {
 	toolService ToolService
+	toolSocket  *ToolSocket
 }
 
-func NewToolController(toolService ToolService) *ToolController {
-	return &ToolController{toolService: toolService}
+func NewToolController(toolService ToolService, toolSocket *ToolSocket) *ToolController {
+	return &ToolController{toolService: toolService, toolSocket: toolSocket}
 }
 
 func (sc *ToolController) GetTools(c *gin.Context) {
@@ -32,6 +36,10 @@ func (sc *ToolController) GetTool(c *gin.Context) {
 	}
 
 	tool, err := sc.toolService.ReadTool(c.Request.Context(), toolID)
+	if errors.Is(err, ErrToolNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -54,6 +62,40 @@ func (sc *ToolController) CreateTool(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{})
 }
 
+func (sc *ToolController) UpdateTool(c *gin.Context) {
+	toolID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dto UpdateToolDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = sc.toolService.UpdateTool(c.Request.Context(), toolID, &dto)
+	if errors.Is(err, ErrToolNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (sc *ToolController) SyncToolRouter(c *gin.Context) {
+	result, err := sc.toolService.ReconcileToolRouter(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 func (sc *ToolController) DeleteTool(c *gin.Context) {
 	toolID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -76,27 +118,48 @@ func (sc *ToolController) GetToolMessages(c *gin.Context) {
 		return
 	}
 
-	messages, err := sc.toolService.ReadAllToolMessages(c.Request.Context(), sessionID)
+	var query ToolMessageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := sc.toolService.ReadToolMessages(c.Request.Context(), sessionID, &query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(toolMessageErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, messages)
+
+	if page.NextCursor != nil {
+		c.Header("X-Next-Cursor", page.NextCursor.String())
+	}
+	c.JSON(http.StatusOK, page.Messages)
 }
 
-func (sc *ToolController) CreateToolMessage(c *gin.Context) {
+// SendToolMessage saves a message and publishes it like a "message" frame on the tool socket,
+// for clients that receive session events over SSE instead. Like the socket, it requires a ticket for the session.
+func (sc *ToolController) SendToolMessage(c *gin.Context) {
 	var dto CreateToolMessageDTO
 	if err := c.ShouldBindJSON(&dto); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if _, ok := sc.toolSocket.guard.Authorize(c, dto.SessionID); !ok {
+		return
+	}
+	if err := sc.toolService.ValidateClientToolMessage(c.Request.Context(), &dto); err != nil {
+		c.JSON(toolMessageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	err := sc.toolService.CreateToolMessage(c.Request.Context(), &dto)
+	toolMessage, err := sc.toolService.CreateToolMessage(c.Request.Context(), &dto)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(toolMessageErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{})
+
+	sc.toolSocket.Publish(toolMessage, c.GetHeader("X-Correlation-ID"))
+	c.JSON(http.StatusCreated, toolMessage)
 }
 
 func (sc *ToolController) SendRequestToToolServer(c *gin.Context) {
@@ -119,3 +182,19 @@ func (sc *ToolController) SendRequestToToolServer(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, response)
 }
+
+func toolMessageErrorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, ErrToolNotFound), errors.Is(err, ErrToolMessageNotFound), errors.Is(err, session.ErrSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, session.ErrSessionReadOnly):
+		return http.StatusConflict
+	case errors.Is(err, ErrRoleNotAllowed):
+		return http.StatusForbidden
+	case errors.As(err, &validationErrors):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
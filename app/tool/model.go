@@ -6,6 +6,12 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	ToolJobStatusStarted  = "started"
+	ToolJobStatusFinished = "finished"
+	ToolJobStatusFailed   = "failed"
+)
+
 const (
 	ToolRoleUser      = "user"
 	ToolRoleAssistant = "assistant"
@@ -19,6 +25,7 @@ type Tool struct {
 	Description       string            `json:"description"`
 	ProviderInterface ProviderInterface `json:"provider_interface"`
 	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         *time.Time        `json:"updated_at"`
 }
 
 type CreateToolDTO struct {
@@ -29,8 +36,22 @@ type CreateToolDTO struct {
 	ProviderInterface ProviderInterface `json:"provider_interface" validate:"required"`
 }
 
+type UpdateToolDTO struct {
+	Name              string            `json:"name" validate:"required"`
+	Version           string            `json:"version"`
+	Description       string            `json:"description" validate:"required"`
+	ProviderInterface ProviderInterface `json:"provider_interface" validate:"required"`
+}
+
+type ToolRouterSyncResult struct {
+	Registered   []uuid.UUID `json:"registered"`
+	Updated      []uuid.UUID `json:"updated"`
+	Unregistered []uuid.UUID `json:"unregistered"`
+}
+
 type ToolMessage struct {
 	ID        uuid.UUID      `json:"id"`
+	Seq       int64          `json:"seq"`
 	SessionID uuid.UUID      `json:"session_id"`
 	ToolID    uuid.UUID      `json:"tool_id"`
 	Role      string         `json:"role"`
@@ -39,10 +60,10 @@ type ToolMessage struct {
 }
 
 type CreateToolMessageDTO struct {
-	SessionID uuid.UUID      `json:"session_id"`
-	ToolID    uuid.UUID      `json:"tool_id"`
+	SessionID uuid.UUID      `json:"session_id" validate:"required"`
+	ToolID    uuid.UUID      `json:"tool_id" validate:"required"`
 	Role      string         `json:"role"`
-	Data      map[string]any `json:"data"`
+	Data      map[string]any `json:"data" validate:"required"`
 }
 
 type ProviderInterface struct {
@@ -74,3 +95,25 @@ type ToolInteractionElement struct {
 	Interface_id string `json:"interface_id" validate:"required"`
 	Content      any    `json:"content" validate:"required"`
 }
+
+type ToolJobProgress struct {
+	ToolID uuid.UUID `json:"tool_id"`
+	Status string    `json:"status"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// ToolMessageQuery is bound from the query of the tool message history. Before and After are message IDs;
+// without either, the latest messages are returned.
+type ToolMessageQuery struct {
+	Before string   `form:"before" validate:"omitempty,uuid,excluded_with=After"`
+	After  string   `form:"after" validate:"omitempty,uuid"`
+	Limit  int      `form:"limit" validate:"omitempty,min=1,max=500"`
+	Role   []string `form:"role" validate:"omitempty,dive,oneof=user assistant system"`
+}
+
+// ToolMessagePage holds messages oldest first. NextCursor is the message to pass as the same
+// before or after parameter for the following page, and nil when there is none.
+type ToolMessagePage struct {
+	Messages   []*ToolMessage
+	NextCursor *uuid.UUID
+}
@@ -3,27 +3,35 @@ package tool
 import (
 	"context"
 
+	"aigendrug.com/aigendrug-cid-2025-server/app/assignment"
+	"aigendrug.com/aigendrug-cid-2025-server/app/auth"
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/inflight"
+	"aigendrug.com/aigendrug-cid-2025-server/app/presence"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func SetupToolRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool) {
+func SetupToolRoutes(c context.Context, router *gin.Engine, db *pgxpool.Pool, toolHub *hub.Hub, tracker *presence.Tracker, guard *auth.SocketGuard, operations *inflight.Tracker, assignments *assignment.Workflow) {
 	toolService := NewToolService(c, db)
-	toolController := NewToolController(toolService)
+	toolSocket := NewToolSocket(db, toolHub, tracker, guard, operations, assignments)
+	toolController := NewToolController(toolService, toolSocket)
 
 	toolRoutes := router.Group("/v1/tool")
 	{
 		toolRoutes.GET("", toolController.GetTools)
 		toolRoutes.GET("/:id", toolController.GetTool)
 		toolRoutes.POST("", toolController.CreateTool)
+		toolRoutes.PUT("/:id", toolController.UpdateTool)
+		toolRoutes.POST("/sync", toolController.SyncToolRouter)
 		toolRoutes.DELETE("/:id", toolController.DeleteTool)
 		toolRoutes.GET("/messages/:session_id", toolController.GetToolMessages)
-		toolRoutes.POST("/messages", toolController.CreateToolMessage)
+		// Older clients still post here; it is the same ticket-checked endpoint as /session/messages
+		toolRoutes.POST("/messages", toolController.SendToolMessage)
 		toolRoutes.GET("/messages", toolController.GetToolMessages)
 		toolRoutes.GET("/send_request/:id", toolController.SendRequestToToolServer)
 
-		toolRoutes.GET("/session/ws", func(ctx *gin.Context) {
-			WebSocketHandler(ctx, db)
-		})
+		toolRoutes.GET("/session/ws", toolSocket.WebSocketHandler)
+		toolRoutes.POST("/session/messages", toolController.SendToolMessage)
 	}
 }
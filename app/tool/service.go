@@ -4,38 +4,59 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"reflect"
+	"slices"
+	"strings"
 	"time"
 
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/database"
+	toolrouter "aigendrug.com/aigendrug-cid-2025-server/tool-router"
 	validator "github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var ErrToolNotFound = errors.New("tool not found")
+var ErrToolMessageNotFound = errors.New("tool message not found")
+var ErrRoleNotAllowed = errors.New("clients may only send user messages")
+
+const defaultMessagePageSize = 100
+
 type ToolService interface {
 	ReadAllTools(rctx context.Context) ([]*Tool, error)
 	ReadTool(rctx context.Context, id uuid.UUID) (*Tool, error)
 	CreateTool(rctx context.Context, dto *CreateToolDTO) error
+	UpdateTool(rctx context.Context, id uuid.UUID, dto *UpdateToolDTO) error
 	DeleteTool(rctx context.Context, id uuid.UUID) error
-	ReadAllToolMessages(rctx context.Context, sessionID uuid.UUID) ([]*ToolMessage, error)
-	CreateToolMessage(rctx context.Context, dto *CreateToolMessageDTO) error
+	SelectTool(rctx context.Context, prompt string) (*Tool, error)
+	ReconcileToolRouter(rctx context.Context) (*ToolRouterSyncResult, error)
+	ReadToolMessages(rctx context.Context, sessionID uuid.UUID, query *ToolMessageQuery) (*ToolMessagePage, error)
+	ReadMissedToolMessages(rctx context.Context, sessionID uuid.UUID, cursor *hub.ResumeCursor, limit int) ([]*ToolMessage, error)
+	CreateToolMessage(rctx context.Context, dto *CreateToolMessageDTO) (*ToolMessage, error)
 	SendRequestToToolServer(rctx context.Context, id uuid.UUID, requestBody []ToolInteractionElement) (string, error)
+	ValidateClientToolMessage(rctx context.Context, dto *CreateToolMessageDTO) error
 }
 
 type toolService struct {
-	ctx context.Context
-	db  *pgxpool.Pool
+	ctx    context.Context
+	db     *pgxpool.Pool
+	router toolrouter.ToolRouterService
 }
 
 func NewToolService(c context.Context, db *pgxpool.Pool) ToolService {
-	return &toolService{ctx: c, db: db}
+	return &toolService{ctx: c, db: db, router: toolrouter.NewToolRouterService(c)}
 }
 
 func (s *toolService) ReadAllTools(rctx context.Context) ([]*Tool, error) {
-	rows, err := s.db.Query(rctx, "SELECT id, name, version, description, provider_interface, created_at FROM tools")
+	rows, err := s.db.Query(rctx, "SELECT id, name, version, description, provider_interface, created_at, updated_at FROM tools")
 	if err != nil {
 		return nil, err
 	}
@@ -52,6 +73,7 @@ func (s *toolService) ReadAllTools(rctx context.Context) ([]*Tool, error) {
 			&tool.Description,
 			&providerInterfaceStr,
 			&tool.CreatedAt,
+			&tool.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -72,8 +94,11 @@ func (s *toolService) ReadTool(rctx context.Context, id uuid.UUID) (*Tool, error
 	var Tool Tool
 	var providerInterfaceStr string
 
-	err := s.db.QueryRow(rctx, "SELECT id, name, version, description, provider_interface, created_at FROM tools WHERE id = $1", id).
-		Scan(&Tool.ID, &Tool.Name, &Tool.Version, &Tool.Description, &providerInterfaceStr, &Tool.CreatedAt)
+	err := s.db.QueryRow(rctx, "SELECT id, name, version, description, provider_interface, created_at, updated_at FROM tools WHERE id = $1", id).
+		Scan(&Tool.ID, &Tool.Name, &Tool.Version, &Tool.Description, &providerInterfaceStr, &Tool.CreatedAt, &Tool.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrToolNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -104,17 +129,179 @@ func (s *toolService) CreateTool(rctx context.Context, dto *CreateToolDTO) error
         INSERT INTO tools (id, name, version, description, provider_interface, created_at)
         VALUES ($1, $2, $3, $4, $5, $6)
     `, dto.ID, dto.Name, dto.Version, dto.Description, string(providerInterfaceStr), time.Now())
-	return err
+	if err != nil {
+		return err
+	}
+
+	s.pushToolToRouter(rctx, dto.ID, dto.Name, dto.Version, dto.Description)
+	return nil
+}
+
+func (s *toolService) UpdateTool(rctx context.Context, id uuid.UUID, dto *UpdateToolDTO) error {
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return fmt.Errorf("tool validation failed: %w", err)
+	}
+
+	if err := validate.Struct(dto.ProviderInterface); err != nil {
+		return fmt.Errorf("provider interface validation failed: %w", err)
+	}
+
+	providerInterfaceStr, err := json.Marshal(dto.ProviderInterface)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.db.Exec(rctx, `
+        UPDATE tools
+        SET name = $2, version = $3, description = $4, provider_interface = $5, updated_at = $6
+        WHERE id = $1
+    `, id, dto.Name, dto.Version, dto.Description, string(providerInterfaceStr), time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrToolNotFound
+	}
+
+	s.pushToolToRouter(rctx, id, dto.Name, dto.Version, dto.Description)
+	return nil
 }
 
 func (s *toolService) DeleteTool(rctx context.Context, id uuid.UUID) error {
 	_, err := s.db.Exec(rctx, "DELETE FROM tools WHERE id = $1", id)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// A failed push is repaired by the next ReconcileToolRouter run.
+	if err := s.router.UnregisterTool(rctx, id); err != nil {
+		log.Println("Failed to unregister tool from router:", err)
+	}
+	return nil
+}
+
+func (s *toolService) pushToolToRouter(rctx context.Context, id uuid.UUID, name, version, description string) {
+	err := s.router.RegisterTool(rctx, &toolrouter.RegisteredTool{
+		ToolID:      id,
+		ToolName:    name,
+		Version:     version,
+		Description: description,
+	})
+	// A failed push is repaired by the next ReconcileToolRouter run.
+	if err != nil {
+		log.Println("Failed to register tool with router:", err)
+	}
+}
+
+// SelectTool asks the tool router for the best tool and only returns it if it exists in the local catalog.
+func (s *toolService) SelectTool(rctx context.Context, prompt string) (*Tool, error) {
+	selected, err := s.router.SelectTool(rctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select tool: %w", err)
+	}
+
+	tool, err := s.ReadTool(rctx, selected.ToolID)
+	if errors.Is(err, ErrToolNotFound) {
+		return nil, fmt.Errorf("router selected unknown tool %s: %w", selected.ToolID, ErrToolNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tool, nil
+}
+
+// ReadToolMessages returns one page of the session's tool messages, ordered by (created_at, id).
+// Pages are read backwards from the latest message with Before, or forwards with After.
+func (s *toolService) ReadToolMessages(rctx context.Context, sessionID uuid.UUID, query *ToolMessageQuery) (*ToolMessagePage, error) {
+	validate := validator.New()
+	if err := validate.Struct(query); err != nil {
+		return nil, fmt.Errorf("tool message query validation failed: %w", err)
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultMessagePageSize
+	}
+	forward := query.After != ""
+
+	conditions := []string{"session_id = $1"}
+	args := []interface{}{sessionID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(query.Role) > 0 {
+		conditions = append(conditions, "role = ANY("+arg(query.Role)+")")
+	}
+	if cursorID := query.Before + query.After; cursorID != "" {
+		var createdAt time.Time
+		err := s.db.QueryRow(rctx, "SELECT created_at FROM tool_messages WHERE id = $1 AND session_id = $2", uuid.MustParse(cursorID), sessionID).Scan(&createdAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrToolMessageNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		comparison := "<"
+		if forward {
+			comparison = ">"
+		}
+		conditions = append(conditions, "(created_at, id) "+comparison+" ("+arg(createdAt)+", "+arg(cursorID)+"::uuid)")
+	}
+
+	direction := "DESC"
+	if forward {
+		direction = "ASC"
+	}
+	messages, err := s.queryToolMessages(rctx, `
+        SELECT id, seq, session_id, tool_id, role, data, created_at
+        FROM tool_messages
+        WHERE `+strings.Join(conditions, " AND ")+`
+        ORDER BY created_at `+direction+`, id `+direction+`
+        LIMIT `+arg(limit+1), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// One extra row tells whether there is a next page; pages are always returned oldest first
+	page := &ToolMessagePage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.NextCursor = &page.Messages[limit-1].ID
+	}
+	if !forward {
+		slices.Reverse(page.Messages)
+	}
+	return page, nil
 }
 
-func (s *toolService) ReadAllToolMessages(rctx context.Context, sessionID uuid.UUID) ([]*ToolMessage, error) {
+// ReadMissedToolMessages returns up to limit messages of the session that come after cursor, in seq order.
+func (s *toolService) ReadMissedToolMessages(rctx context.Context, sessionID uuid.UUID, cursor *hub.ResumeCursor, limit int) ([]*ToolMessage, error) {
+	afterSeq := cursor.LastSeq
+	if cursor.LastMessageID != nil {
+		err := s.db.QueryRow(rctx, "SELECT seq FROM tool_messages WHERE id = $1 AND session_id = $2", *cursor.LastMessageID, sessionID).Scan(&afterSeq)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrToolMessageNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.queryToolMessages(rctx, `
+        SELECT id, seq, session_id, tool_id, role, data, created_at
+        FROM tool_messages
+        WHERE session_id = $1 AND seq > $2 AND ($3::timestamp IS NULL OR created_at > $3)
+        ORDER BY seq
+        LIMIT $4
+    `, sessionID, afterSeq, cursor.Since, limit)
+}
+
+func (s *toolService) queryToolMessages(rctx context.Context, query string, args ...any) ([]*ToolMessage, error) {
 	var ToolMessages []*ToolMessage
-	rows, err := s.db.Query(rctx, "SELECT id, session_id, tool_id, role, data, created_at FROM tool_messages WHERE session_id = $1", sessionID)
+	rows, err := s.db.Query(rctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -125,6 +312,7 @@ func (s *toolService) ReadAllToolMessages(rctx context.Context, sessionID uuid.U
 		var dataStr string
 		if err := rows.Scan(
 			&ToolMessage.ID,
+			&ToolMessage.Seq,
 			&ToolMessage.SessionID,
 			&ToolMessage.ToolID,
 			&ToolMessage.Role,
@@ -146,18 +334,61 @@ func (s *toolService) ReadAllToolMessages(rctx context.Context, sessionID uuid.U
 	return ToolMessages, nil
 }
 
-func (s *toolService) CreateToolMessage(rctx context.Context, dto *CreateToolMessageDTO) error {
+// ValidateClientToolMessage checks a message sent by a client before it is saved. The role defaults to
+// and must be user, since assistant messages are only written by the server, and the tool must exist.
+func (s *toolService) ValidateClientToolMessage(rctx context.Context, dto *CreateToolMessageDTO) error {
+	if dto.Role == "" {
+		dto.Role = ToolRoleUser
+	}
+	if dto.Role != ToolRoleUser {
+		return ErrRoleNotAllowed
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		return fmt.Errorf("tool message validation failed: %w", err)
+	}
+
+	_, err := s.ReadTool(rctx, dto.ToolID)
+	return err
+}
+
+// CreateToolMessage saves the message while holding the session row, so seq order matches commit order
+// and replays after a reconnect never skip a message that committed late. Archived and closed sessions refuse it.
+func (s *toolService) CreateToolMessage(rctx context.Context, dto *CreateToolMessageDTO) (*ToolMessage, error) {
 	var dataStr []byte
 	dataStr, err := json.Marshal(dto.Data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = s.db.Exec(rctx, `
-        INSERT INTO tool_messages (session_id, tool_id, role, data, created_at)
-        VALUES ($1, $2, $3, $4, $5)
-    `, dto.SessionID, dto.ToolID, dto.Role, string(dataStr), time.Now())
-	return err
+	toolMessage := &ToolMessage{
+		ID:        uuid.New(),
+		SessionID: dto.SessionID,
+		ToolID:    dto.ToolID,
+		Role:      dto.Role,
+		Data:      dto.Data,
+		CreatedAt: time.Now(),
+	}
+
+	err = database.WithTx(rctx, s.db, func(tx pgx.Tx) error {
+		if _, err := session.LockWritableSession(rctx, tx, toolMessage.SessionID); err != nil {
+			return err
+		}
+		err := tx.QueryRow(rctx, `
+            INSERT INTO tool_messages (id, session_id, tool_id, role, data, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING seq
+        `, toolMessage.ID, toolMessage.SessionID, toolMessage.ToolID, toolMessage.Role, string(dataStr), toolMessage.CreatedAt).Scan(&toolMessage.Seq)
+		if err != nil {
+			return err
+		}
+		return session.TouchSession(rctx, tx, toolMessage.SessionID, toolMessage.CreatedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toolMessage, nil
 }
 
 func (s *toolService) SendRequestToToolServer(rctx context.Context, toolID uuid.UUID, requestBody []ToolInteractionElement) (string, error) {
@@ -0,0 +1,107 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	toolrouter "aigendrug.com/aigendrug-cid-2025-server/tool-router"
+	"github.com/google/uuid"
+)
+
+// ReconcileToolRouter makes the router catalog match the local tools table.
+// Local tools missing or outdated in the router are registered, and router tools unknown here are removed.
+func (s *toolService) ReconcileToolRouter(rctx context.Context) (*ToolRouterSyncResult, error) {
+	localTools, err := s.readToolCatalog(rctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local tools: %w", err)
+	}
+
+	remoteTools, err := s.router.ListTools(rctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list router tools: %w", err)
+	}
+
+	remoteByID := make(map[uuid.UUID]*toolrouter.RegisteredTool, len(remoteTools))
+	for _, remote := range remoteTools {
+		if remote == nil {
+			continue
+		}
+		remoteByID[remote.ToolID] = remote
+	}
+
+	result := &ToolRouterSyncResult{
+		Registered:   []uuid.UUID{},
+		Updated:      []uuid.UUID{},
+		Unregistered: []uuid.UUID{},
+	}
+
+	for _, expected := range localTools {
+		remote, exists := remoteByID[expected.ToolID]
+		delete(remoteByID, expected.ToolID)
+		if exists && *remote == *expected {
+			continue
+		}
+
+		if err := s.router.RegisterTool(rctx, expected); err != nil {
+			return result, fmt.Errorf("failed to register tool %s: %w", expected.ToolID, err)
+		}
+		if exists {
+			result.Updated = append(result.Updated, expected.ToolID)
+		} else {
+			result.Registered = append(result.Registered, expected.ToolID)
+		}
+	}
+
+	for id := range remoteByID {
+		if err := s.router.UnregisterTool(rctx, id); err != nil {
+			return result, fmt.Errorf("failed to unregister tool %s: %w", id, err)
+		}
+		result.Unregistered = append(result.Unregistered, id)
+	}
+
+	return result, nil
+}
+
+// readToolCatalog reads the fields the router catalog holds for every local tool.
+// Provider interfaces are not decoded, so a tool with a malformed one is still kept in the router.
+func (s *toolService) readToolCatalog(rctx context.Context) ([]*toolrouter.RegisteredTool, error) {
+	rows, err := s.db.Query(rctx, "SELECT id, name, version, description FROM tools")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	catalog := []*toolrouter.RegisteredTool{}
+	for rows.Next() {
+		var entry toolrouter.RegisteredTool
+		if err := rows.Scan(&entry.ToolID, &entry.ToolName, &entry.Version, &entry.Description); err != nil {
+			return nil, err
+		}
+		catalog = append(catalog, &entry)
+	}
+	return catalog, rows.Err()
+}
+
+// RunToolRouterSync reconciles the router catalog every interval until ctx is cancelled.
+func RunToolRouterSync(ctx context.Context, toolService ToolService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := toolService.ReconcileToolRouter(ctx)
+		if err != nil {
+			log.Println("Tool router sync failed:", err)
+		} else if len(result.Registered)+len(result.Updated)+len(result.Unregistered) > 0 {
+			log.Printf("Tool router synced: %d registered, %d updated, %d unregistered",
+				len(result.Registered), len(result.Updated), len(result.Unregistered))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,178 +2,251 @@ package tool
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"sync"
-
-	"time"
 
+	"aigendrug.com/aigendrug-cid-2025-server/app/assignment"
+	"aigendrug.com/aigendrug-cid-2025-server/app/auth"
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/inflight"
+	"aigendrug.com/aigendrug-cid-2025-server/app/presence"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/openai/openai-go"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+/*
+ToolSocket serves the tool WebSocket. Every frame in both directions is a hub.Envelope.
+
+Clients send "message" frames carrying a CreateToolMessageDTO, answered with an "ack" or an "error".
+Connections must come from an allowed origin, carry a ticket for the session (see auth.SocketGuard) and name an
+existing session; messages are always saved in that session, only with the user role
+and only for existing tools.
+Saved messages are broadcast to the session as "message" frames. Assistant answers to user messages are
+reported with "tool-job-progress" frames (started, then finished or failed) around the answer itself,
+and the tool runner is listed as a participant of the session while it works. Runs of the tool assigned
+to the session also move its tool status to running and then completed or failed; see assignment.Workflow.
+*/
+
+type ToolSocket struct {
+	db          *pgxpool.Pool
+	hub         *hub.Hub
+	presence    *presence.Tracker
+	guard       *auth.SocketGuard
+	operations  *inflight.Tracker
+	assignments *assignment.Workflow
 }
 
-var sessionClients = make(map[string]map[*websocket.Conn]bool) // Dynamic map of sessionID to clients
-var broadcast = make(chan ToolMessage)                         // Sync channel for broadcasting messages
-var mutex = sync.Mutex{}                                       // Mutex for sessionClients
+func NewToolSocket(db *pgxpool.Pool, h *hub.Hub, tracker *presence.Tracker, guard *auth.SocketGuard, operations *inflight.Tracker, assignments *assignment.Workflow) *ToolSocket {
+	return &ToolSocket{db: db, hub: h, presence: tracker, guard: guard, operations: operations, assignments: assignments}
+}
 
-func generateAIResponse(message string) string {
+func generateAIResponse(ctx context.Context, message string) (string, error) {
 	client := openai.NewClient()
-	chatCompletion, err := client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
+	chatCompletion, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
 		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
 			openai.UserMessage(message),
 		}),
 		Model: openai.F(openai.ChatModelGPT4o),
 	})
 	if err != nil {
-		panic(err.Error())
+		return "", err
+	}
+	if len(chatCompletion.Choices) == 0 {
+		return "", fmt.Errorf("AI response has no choices")
 	}
 
-	return chatCompletion.Choices[0].Message.Content
+	return chatCompletion.Choices[0].Message.Content, nil
 }
 
-func WebSocketHandler(c *gin.Context, db *pgxpool.Pool) {
-	sessionID := c.Query("sessionID")
-	if sessionID == "" {
-		c.JSON(400, gin.H{"error": "sessionID is required"})
+func (ts *ToolSocket) WebSocketHandler(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Query("sessionID"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "sessionID must be a valid session id"})
+		return
+	}
+	if _, err := session.NewSessionService(c.Request.Context(), ts.db).ReadSession(c.Request.Context(), sessionID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, session.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	// Reconnecting clients pass the last message they saw to receive everything they missed
+	cursor, err := hub.ParseResumeCursor(c.Request.URL.Query())
 	if err != nil {
-		log.Println("WebSocket Upgrade Error:", err)
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	defer conn.Close()
+	replay := ts.replayer(sessionID, cursor)
 
-	mutex.Lock()
-	if sessionClients[sessionID] == nil {
-		sessionClients[sessionID] = make(map[*websocket.Conn]bool)
+	conn := ts.guard.Upgrade(c, sessionID)
+	if conn == nil {
+		return
 	}
-	sessionClients[sessionID][conn] = true
-	mutex.Unlock()
 
-	for {
-		var msg CreateToolMessageDTO
-		err := conn.ReadJSON(&msg)
+	toolService := NewToolService(context.Background(), ts.db)
 
+	ts.hub.ServeWebSocket(conn, sessionID.String(), hub.SocketOptions{Replay: replay}, func(client *hub.Client, data []byte) {
+		envelope, err := hub.ParseEnvelope(data)
 		if err != nil {
-			log.Println("Read Error:", err)
-			mutex.Lock()
-			delete(sessionClients[sessionID], conn)
-			mutex.Unlock()
-			break
+			ts.reject(client, envelope, hub.ErrorCodeInvalidFrame, err)
+			return
+		}
+		if envelope.Type != hub.EventMessage {
+			ts.reject(client, envelope, hub.ErrorCodeUnknownType, fmt.Errorf("unknown event type %q", envelope.Type))
+			return
+		}
+
+		var dto CreateToolMessageDTO
+		if err := envelope.DecodePayload(&dto); err != nil {
+			ts.reject(client, envelope, hub.ErrorCodeInvalidPayload, err)
+			return
 		}
 
-		err = saveChatMessageToDB(db, &msg)
+		// Clients can only post into the session they are connected to, and only as the user
+		dto.SessionID = sessionID
+		if err := toolService.ValidateClientToolMessage(context.Background(), &dto); err != nil {
+			ts.reject(client, envelope, hub.ErrorCodeValidationFailed, err)
+			return
+		}
+
+		toolMsg, err := toolService.CreateToolMessage(context.Background(), &dto)
 		if err != nil {
 			log.Println("DB Save Error:", err)
-			continue
+			code := hub.ErrorCodeSaveFailed
+			if errors.Is(err, session.ErrSessionReadOnly) {
+				code = hub.ErrorCodeSessionReadOnly
+			}
+			ts.reject(client, envelope, code, err)
+			return
 		}
 
-		broadcast <- ToolMessage{
-			SessionID: msg.SessionID,
-			ToolID:    msg.ToolID,
-			Role:      msg.Role,
-			Data:      msg.Data,
+		ack, err := hub.NewEnvelope(hub.EventAck, envelope.ID, hub.AckPayload{ID: toolMsg.ID.String()})
+		if err == nil {
+			ts.hub.SendTo(client, ack)
 		}
+		ts.Publish(toolMsg, envelope.ID)
+	})
+}
+
+// Publish broadcasts a saved message and, if it is from the user, starts generating the AI response.
+// correlationID is the id of the client frame that created the message, if any.
+func (ts *ToolSocket) Publish(msg *ToolMessage, correlationID string) {
+	ts.broadcastMessage(msg, correlationID)
+
+	if msg.Role == ToolRoleUser {
+		go ts.respondToUserMessage(*msg)
 	}
 }
 
-func saveChatMessageToDB(db *pgxpool.Pool, msg *CreateToolMessageDTO) error {
-	newUUID := uuid.New()
+// reject sends an error frame to the client that sent envelope, which may be nil for unparseable frames
+func (ts *ToolSocket) reject(client *hub.Client, envelope *hub.Envelope, code string, err error) {
+	correlationID := ""
+	if envelope != nil {
+		correlationID = envelope.ID
+	}
+	ts.hub.SendTo(client, hub.NewErrorEnvelope(correlationID, code, err.Error()))
+}
 
-	dataStr, err := json.Marshal(msg.Data)
+func (ts *ToolSocket) broadcast(sessionID uuid.UUID, eventType string, correlationID string, payload any) {
+	envelope, err := hub.NewEnvelope(eventType, correlationID, payload)
+	if err == nil {
+		err = ts.hub.Broadcast(sessionID.String(), envelope)
+	}
 	if err != nil {
-		return err
-	}
-
-	_, err = db.Exec(
-		context.Background(),
-		`INSERT INTO tool_messages (id, session_id, tool_id, role, data, created_at)
-         VALUES ($1, $2, $3, $4, $5, $6)`,
-		newUUID,
-		msg.SessionID,
-		msg.ToolID,
-		msg.Role,
-		string(dataStr),
-		time.Now(),
-	)
-	return err
+		log.Println("Broadcast Error:", err)
+	}
 }
 
-func HandleMessages(db *pgxpool.Pool) {
-	for {
-		msg := <-broadcast
+func (ts *ToolSocket) broadcastMessage(msg *ToolMessage, correlationID string) {
+	envelope, err := hub.NewMessageEnvelope(correlationID, msg.Seq, msg)
+	if err == nil {
+		err = ts.hub.Broadcast(msg.SessionID.String(), envelope)
+	}
+	if err != nil {
+		log.Println("Broadcast Error:", err)
+	}
+}
 
-		mutex.Lock()
-		clients, exists := sessionClients[msg.SessionID.String()]
-		if !exists {
-			mutex.Unlock()
-			continue
-		}
+// replayer returns nil when the client did not ask to resume
+func (ts *ToolSocket) replayer(sessionID uuid.UUID, cursor *hub.ResumeCursor) hub.Replayer {
+	if !cursor.IsSet() {
+		return nil
+	}
 
-		for client := range clients {
-			err := client.WriteJSON(msg)
-			if err != nil {
-				log.Println("Send Error:", err)
-				client.Close()
-				delete(clients, client)
-			}
+	return func(send func(envelope *hub.Envelope) error) (int64, error) {
+		ctx := context.Background()
+		messages, err := NewToolService(ctx, ts.db).ReadMissedToolMessages(ctx, sessionID, cursor, hub.MaxReplayMessages+1)
+		if err != nil {
+			return 0, err
 		}
-		mutex.Unlock()
+		return hub.SendReplay(send, cursor, messages, func(msg *ToolMessage) int64 { return msg.Seq })
+	}
+}
 
-		if msg.Role == ToolRoleUser {
-			aiResponse := generateAIResponse(msg.Data["message"].(string))
+func (ts *ToolSocket) respondToUserMessage(msg ToolMessage) {
+	jobID := uuid.NewString()
+	progress := func(status string, detail string) {
+		ts.broadcast(msg.SessionID, hub.EventToolJobProgress, jobID, ToolJobProgress{ToolID: msg.ToolID, Status: status, Detail: detail})
+		switch status {
+		case ToolJobStatusStarted:
+			ts.assignments.RunStarted(msg.SessionID, msg.ToolID)
+		case ToolJobStatusFinished, ToolJobStatusFailed:
+			ts.assignments.RunFinished(msg.SessionID, msg.ToolID, status == ToolJobStatusFailed)
+		}
+	}
 
-			aiMsg := ToolMessage{
-				SessionID: msg.SessionID,
-				ToolID:    msg.ToolID,
-				Role:      ToolRoleAssistant,
-				Data:      map[string]interface{}{"message": aiResponse},
-				CreatedAt: msg.CreatedAt,
-			}
-			err := saveChatMessageToDB(db, &CreateToolMessageDTO{
-				SessionID: msg.SessionID,
-				ToolID:    msg.ToolID,
-				Role:      ToolRoleAssistant,
-				Data:      map[string]interface{}{"message": aiResponse},
-			})
-			if err != nil {
-				log.Println("Failed to save AI response:", err)
-				continue
-			}
+	message, ok := msg.Data["message"].(string)
+	if !ok {
+		return
+	}
 
-			finishMsg := map[string]interface{}{
-				"status": "finished",
-			}
+	operation, err := ts.operations.Start(inflight.OperationKindToolResponse, msg.SessionID, &msg.ID)
+	if err != nil {
+		ts.broadcast(msg.SessionID, hub.EventError, jobID, hub.ErrorPayload{Code: hub.ErrorCodeAIFailed, Message: err.Error()})
+		return
+	}
+	defer operation.Finish()
+
+	progress(ToolJobStatusStarted, "")
+	ts.presence.SetAgentState(msg.SessionID, presence.ParticipantKindTool, presence.ParticipantStateRunning)
+	defer ts.presence.SetAgentState(msg.SessionID, presence.ParticipantKindTool, presence.ParticipantStateIdle)
+
+	aiResponse, err := generateAIResponse(operation.Context(), message)
+	if err != nil && operation.Interrupted() {
+		log.Println("AI response interrupted by shutdown:", err)
+		operation.Interrupt("")
+		ts.broadcast(msg.SessionID, hub.EventError, jobID, hub.ErrorPayload{Code: hub.ErrorCodeInterrupted, Message: inflight.ErrShuttingDown.Error()})
+		progress(ToolJobStatusFailed, inflight.ErrShuttingDown.Error())
+		return
+	}
+	if err != nil {
+		log.Println("Failed to generate AI response:", err)
+		ts.broadcast(msg.SessionID, hub.EventError, jobID, hub.ErrorPayload{Code: hub.ErrorCodeAIFailed, Message: err.Error()})
+		progress(ToolJobStatusFailed, err.Error())
+		return
+	}
 
-			mutex.Lock()
-			for client := range clients {
-				err := client.WriteJSON(aiMsg)
-				if err != nil {
-					log.Println("Send Error:", err)
-					client.Close()
-					delete(clients, client)
-				}
-
-				err = client.WriteJSON(finishMsg)
-				if err != nil {
-					log.Println("Send Error:", err)
-					client.Close()
-					delete(clients, client)
-				}
-			}
-			mutex.Unlock()
-		}
+	aiMsg, err := NewToolService(context.Background(), ts.db).CreateToolMessage(context.Background(), &CreateToolMessageDTO{
+		SessionID: msg.SessionID,
+		ToolID:    msg.ToolID,
+		Role:      ToolRoleAssistant,
+		Data:      map[string]interface{}{"message": aiResponse},
+	})
+	if err != nil {
+		log.Println("Failed to save AI response:", err)
+		ts.broadcast(msg.SessionID, hub.EventError, jobID, hub.ErrorPayload{Code: hub.ErrorCodeSaveFailed, Message: err.Error()})
+		progress(ToolJobStatusFailed, err.Error())
+		return
 	}
+
+	ts.broadcastMessage(aiMsg, jobID)
+	progress(ToolJobStatusFinished, "")
 }
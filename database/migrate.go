@@ -2,8 +2,10 @@ package database
 
 import (
 	"context"
-	_ "embed"
+	"embed"
 	"fmt"
+	"io/fs"
+	"sort"
 
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -12,6 +14,9 @@ import (
 //go:embed sql/init.sql
 var initial_sql string
 
+//go:embed sql/migrations/*.sql
+var migrations embed.FS
+
 func AutoMigrateFromConnectionString(ctx context.Context, connectionString string, config *pgxpool.Config) (bool, error) {
 	dbName := config.ConnConfig.Database
 	config.ConnConfig.Database = "postgres"
@@ -46,5 +51,29 @@ func AutoMigrateFromConnectionString(ctx context.Context, connectionString strin
 	}
 	println("Database initialized successfully")
 
+	if err := applyMigrations(ctx, dbTarget); err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
+
+// Migrations are applied in file name order on every startup, so each file must be idempotent.
+func applyMigrations(ctx context.Context, db *pgxpool.Pool) error {
+	names, err := fs.Glob(migrations, "sql/migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		migration, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(ctx, string(migration)); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"os"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -14,7 +15,9 @@ func NewPostgresPool(c context.Context) (*pgxpool.Pool, error) {
 		return nil, err
 	}
 
-	AutoMigrateFromConnectionString(c, os.Getenv("DB_CONNECTION_STRING"), config)
+	if _, err := AutoMigrateFromConnectionString(c, os.Getenv("DB_CONNECTION_STRING"), config); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
 
 	dbpool, err := pgxpool.NewWithConfig(c, config)
 
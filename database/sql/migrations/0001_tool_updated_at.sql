@@ -0,0 +1,4 @@
+SET search_path TO ks_admin;
+
+-- Track tool catalog changes so they can be pushed to the tool router
+ALTER TABLE tools ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
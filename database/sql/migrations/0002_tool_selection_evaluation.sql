@@ -0,0 +1,28 @@
+SET search_path TO ks_admin;
+
+-- Labelled prompt -> expected tool cases used to evaluate the tool router
+CREATE TABLE IF NOT EXISTS tool_selection_cases (
+    id UUID PRIMARY KEY,
+    prompt TEXT NOT NULL,
+    expected_tool_id UUID NOT NULL,
+    source TEXT,
+    selection_id UUID,
+    created_at TIMESTAMP
+);
+
+-- Tool selections made in production and how the user responded to them
+CREATE TABLE IF NOT EXISTS tool_selections (
+    id UUID PRIMARY KEY,
+    session_id UUID NOT NULL,
+    message_id UUID,
+    prompt TEXT,
+    selected_tool_id UUID,
+    outcome TEXT,
+    final_tool_id UUID,
+    created_at TIMESTAMP,
+    resolved_at TIMESTAMP,
+    CONSTRAINT fk_session_selection FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_selections_session_id ON tool_selections(session_id);
+CREATE INDEX IF NOT EXISTS idx_tool_selections_selected_tool_id ON tool_selections(selected_tool_id);
@@ -0,0 +1,19 @@
+SET search_path TO ks_admin;
+
+-- User ratings of assistant answers and tool recommendations
+CREATE TABLE IF NOT EXISTS chat_message_feedback (
+    id UUID PRIMARY KEY,
+    message_id UUID NOT NULL,
+    session_id UUID NOT NULL,
+    rating TEXT NOT NULL,
+    tool_id UUID,
+    corrected_tool_id UUID,
+    comment TEXT,
+    prompt_category TEXT,
+    created_at TIMESTAMP,
+    CONSTRAINT fk_feedback_message FOREIGN KEY (message_id) REFERENCES chat_messages(id),
+    CONSTRAINT fk_feedback_session FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_message_feedback_message_id ON chat_message_feedback(message_id);
+CREATE INDEX IF NOT EXISTS idx_chat_message_feedback_tool_id ON chat_message_feedback(tool_id);
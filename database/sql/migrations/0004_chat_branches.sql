@@ -0,0 +1,33 @@
+SET search_path TO ks_admin;
+
+-- Branching chat history: every message points to its parent and belongs to a branch
+CREATE TABLE IF NOT EXISTS chat_branches (
+    id UUID PRIMARY KEY,
+    session_id UUID NOT NULL,
+    parent_branch_id UUID,
+    fork_message_id UUID,
+    created_at TIMESTAMP,
+    CONSTRAINT fk_session_branch FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS parent_id UUID;
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS branch_id UUID;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS active_branch_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_chat_branches_session_id ON chat_branches(session_id);
+CREATE INDEX IF NOT EXISTS idx_chat_messages_branch_id ON chat_messages(branch_id, created_at);
+
+-- The main branch of a session shares the session's ID
+INSERT INTO chat_branches (id, session_id, created_at)
+SELECT id, id, created_at FROM sessions
+ON CONFLICT (id) DO NOTHING;
+
+-- Link existing linear histories into the main branch
+UPDATE chat_messages m
+SET branch_id = m.session_id, parent_id = ordered.prev_id
+FROM (
+    SELECT id, LAG(id) OVER (PARTITION BY session_id ORDER BY created_at, id) AS prev_id
+    FROM chat_messages
+    WHERE branch_id IS NULL
+) ordered
+WHERE m.id = ordered.id;
@@ -0,0 +1,19 @@
+SET search_path TO ks_admin;
+
+-- Versioned system prompt templates, optionally scoped to a single tool
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    tool_id UUID,
+    body TEXT NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    weight INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP,
+    CONSTRAINT uq_prompt_template_version UNIQUE (name, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_prompt_templates_tool_id ON prompt_templates(tool_id);
+
+-- Which template version produced an assistant message
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS prompt_template_id UUID;
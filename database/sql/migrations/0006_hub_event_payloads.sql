@@ -0,0 +1,10 @@
+SET search_path TO ks_admin;
+
+-- Hub messages too large for a NOTIFY payload are passed between instances through this table
+CREATE TABLE IF NOT EXISTS hub_event_payloads (
+    id UUID PRIMARY KEY,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_hub_event_payloads_created_at ON hub_event_payloads(created_at);
@@ -0,0 +1,41 @@
+SET search_path TO ks_admin;
+
+-- Server-assigned, strictly increasing sequence numbers give messages a stable order and let
+-- reconnecting clients ask for everything after the last message they saw.
+-- Existing messages are numbered in (created_at, id) order before the identity is attached, because
+-- adding an identity column directly would number them in physical row order.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'chat_messages' AND column_name = 'seq'
+    ) THEN
+        ALTER TABLE chat_messages ADD COLUMN seq BIGINT;
+        UPDATE chat_messages m
+        SET seq = ordered.rn
+        FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY created_at, id) AS rn FROM chat_messages) ordered
+        WHERE m.id = ordered.id;
+        ALTER TABLE chat_messages ALTER COLUMN seq SET NOT NULL;
+        ALTER TABLE chat_messages ALTER COLUMN seq ADD GENERATED ALWAYS AS IDENTITY;
+        PERFORM setval(pg_get_serial_sequence('chat_messages', 'seq'),
+            COALESCE((SELECT MAX(seq) FROM chat_messages), 0) + 1, false);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'tool_messages' AND column_name = 'seq'
+    ) THEN
+        ALTER TABLE tool_messages ADD COLUMN seq BIGINT;
+        UPDATE tool_messages m
+        SET seq = ordered.rn
+        FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY created_at, id) AS rn FROM tool_messages) ordered
+        WHERE m.id = ordered.id;
+        ALTER TABLE tool_messages ALTER COLUMN seq SET NOT NULL;
+        ALTER TABLE tool_messages ALTER COLUMN seq ADD GENERATED ALWAYS AS IDENTITY;
+        PERFORM setval(pg_get_serial_sequence('tool_messages', 'seq'),
+            COALESCE((SELECT MAX(seq) FROM tool_messages), 0) + 1, false);
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_messages_session_seq ON chat_messages(session_id, seq);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tool_messages_session_seq ON tool_messages(session_id, seq);
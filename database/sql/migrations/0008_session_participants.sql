@@ -0,0 +1,20 @@
+SET search_path TO ks_admin;
+
+-- Who is currently connected to a session and what they are doing. Rows belong to the server instance
+-- holding the connection, which refreshes updated_at; rows of crashed instances expire.
+CREATE TABLE IF NOT EXISTS session_participants (
+    id UUID PRIMARY KEY,
+    session_id UUID NOT NULL,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    state TEXT NOT NULL,
+    instance_id TEXT NOT NULL,
+    joined_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_session_participant FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_participants_session_id ON session_participants(session_id);
+CREATE INDEX IF NOT EXISTS idx_session_participants_instance_id ON session_participants(instance_id);
+-- The assistant and the tool runner appear at most once per session
+CREATE UNIQUE INDEX IF NOT EXISTS idx_session_participants_agent ON session_participants(session_id, kind) WHERE kind <> 'user';
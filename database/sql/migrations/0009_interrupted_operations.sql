@@ -0,0 +1,15 @@
+SET search_path TO ks_admin;
+
+-- AI answers and tool runs cut short by a server shutdown, kept so they can be inspected and retried
+CREATE TABLE IF NOT EXISTS interrupted_operations (
+    id UUID PRIMARY KEY,
+    kind TEXT NOT NULL,
+    session_id UUID NOT NULL,
+    source_message_id UUID,
+    partial_output TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP NOT NULL,
+    interrupted_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_session_interrupted_operation FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_interrupted_operations_session_id ON interrupted_operations(session_id);
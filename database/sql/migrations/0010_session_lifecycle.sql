@@ -0,0 +1,20 @@
+SET search_path TO ks_admin;
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+
+UPDATE sessions SET status = 'active' WHERE status IS NULL OR status = '';
+UPDATE sessions SET updated_at = created_at WHERE updated_at IS NULL;
+UPDATE sessions SET status_changed_at = created_at WHERE status_changed_at IS NULL;
+
+-- Every status change of a session, starting with its creation (from_status NULL)
+CREATE TABLE IF NOT EXISTS session_status_history (
+    id UUID PRIMARY KEY,
+    session_id UUID NOT NULL,
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    changed_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_session_status_history FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_status_history_session_id ON session_status_history(session_id, changed_at);
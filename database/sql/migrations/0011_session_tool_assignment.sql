@@ -0,0 +1,8 @@
+SET search_path TO ks_admin;
+
+-- The tool selection behind the current assignment, resolved when the user confirms or overrides it
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS tool_selection_id UUID;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS tool_status_changed_at TIMESTAMP;
+
+-- Sessions used to be created with an empty tool status; no tool has been assigned to them
+UPDATE sessions SET tool_status = NULL WHERE tool_status = '';
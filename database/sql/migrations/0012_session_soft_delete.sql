@@ -0,0 +1,6 @@
+SET search_path TO ks_admin;
+
+-- Deleted sessions stay in the trash until they are restored or purged after the retention window
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_deleted_at ON sessions(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,16 @@
+SET search_path TO ks_admin;
+
+-- Full-text search over session names and chat message content
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('english', coalesce(name, ''))) STORED;
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('english', coalesce(message, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_search_vector ON sessions USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_chat_messages_search_vector ON chat_messages USING GIN (search_vector);
+
+-- Keyset pagination of the session list, newest first by default
+UPDATE sessions SET updated_at = created_at WHERE updated_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_sessions_created_at ON sessions(created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_sessions_updated_at ON sessions(updated_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_sessions_assigned_tool_id ON sessions(assigned_tool_id) WHERE deleted_at IS NULL;
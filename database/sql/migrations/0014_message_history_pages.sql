@@ -0,0 +1,5 @@
+SET search_path TO ks_admin;
+
+-- Keyset pagination of message histories on (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_chat_messages_session_created_id ON chat_messages(session_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_tool_messages_session_created_id ON tool_messages(session_id, created_at, id);
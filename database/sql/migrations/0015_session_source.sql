@@ -0,0 +1,7 @@
+SET search_path TO ks_admin;
+
+-- The session a clone or an import was copied from. Imported sessions keep the ID they had in the
+-- other environment, so the source does not have to exist here.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS source_session_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_source_session_id ON sessions(source_session_id) WHERE source_session_id IS NOT NULL;
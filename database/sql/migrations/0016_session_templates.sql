@@ -0,0 +1,19 @@
+SET search_path TO ks_admin;
+
+-- Reusable starting points for recurring workflows: a pre-assigned tool, seeded chat messages and
+-- default tool parameters. Seed messages and parameters are stored as JSON text.
+CREATE TABLE IF NOT EXISTS session_templates (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    tool_id UUID,
+    seed_messages TEXT NOT NULL DEFAULT '[]',
+    default_parameters TEXT NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_templates_name ON session_templates(name);
+
+-- The template a session was created from
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS template_id UUID;
@@ -0,0 +1,10 @@
+SET search_path TO ks_admin;
+
+-- Free-form tags, markdown notes and a metadata map (target protein, project code, compound series)
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+-- Tag filters use @> on tags; metadata filters use @> and the key lookups use ?
+CREATE INDEX IF NOT EXISTS idx_sessions_tags ON sessions USING GIN (tags) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_sessions_metadata ON sessions USING GIN (metadata) WHERE deleted_at IS NULL;
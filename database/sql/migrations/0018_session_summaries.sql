@@ -0,0 +1,8 @@
+SET search_path TO ks_admin;
+
+-- Generated from the conversation in the background; name stays what the user typed
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS title TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS summary TEXT;
+-- When the summarized messages were read and how many chat messages the session had then
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS summarized_at TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS summarized_message_count INTEGER NOT NULL DEFAULT 0;
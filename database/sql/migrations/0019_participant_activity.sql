@@ -0,0 +1,6 @@
+SET search_path TO ks_admin;
+
+-- Number of operations an agent is working on in the session; the agent is removed when it drops to zero
+ALTER TABLE session_participants ADD COLUMN IF NOT EXISTS operations INTEGER NOT NULL DEFAULT 0;
+-- A typing user falls back to idle at this time unless the client reports typing again
+ALTER TABLE session_participants ADD COLUMN IF NOT EXISTS typing_until TIMESTAMP;
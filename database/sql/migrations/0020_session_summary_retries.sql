@@ -0,0 +1,5 @@
+SET search_path TO ks_admin;
+
+-- Sessions whose summary failed are retried with a growing delay, so they do not hold up the others
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS summary_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS summary_retry_at TIMESTAMP;
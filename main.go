@@ -2,15 +2,29 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"aigendrug.com/aigendrug-cid-2025-server/app"
-	"aigendrug.com/aigendrug-cid-2025-server/app/chat"
+	"aigendrug.com/aigendrug-cid-2025-server/app/assignment"
+	"aigendrug.com/aigendrug-cid-2025-server/app/auth"
+	"aigendrug.com/aigendrug-cid-2025-server/app/hub"
+	"aigendrug.com/aigendrug-cid-2025-server/app/inflight"
+	"aigendrug.com/aigendrug-cid-2025-server/app/presence"
+	"aigendrug.com/aigendrug-cid-2025-server/app/session"
+	"aigendrug.com/aigendrug-cid-2025-server/app/summary"
 	"aigendrug.com/aigendrug-cid-2025-server/app/tool"
 	"aigendrug.com/aigendrug-cid-2025-server/database"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/joho/godotenv"
 )
 
@@ -35,9 +49,13 @@ func main() {
 		port = "8080"
 	}
 
-	router := gin.Default()
+	// Like gin.Default(), but socket and stream tickets in the query string are redacted from the access log
+	router := gin.New()
+	router.Use(gin.LoggerWithFormatter(auth.AccessLogFormatter), gin.Recovery())
 
-	ctx := context.Background()
+	// Cancelled on SIGINT/SIGTERM, which stops the background workers and starts the shutdown below
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	pool, err := database.NewPostgresPool(ctx)
 	if err != nil {
@@ -45,19 +63,29 @@ func main() {
 	}
 	defer pool.Close()
 
-	router.Use(cors.New(cors.Config{
-		AllowOrigins: []string{
-			"https://aigendrug-cid-2025.luidium.com",
-			"http://localhost:3000",
-		},
-		AllowMethods: []string{"PUT", "POST", "GET", "DELETE", "OPTIONS"},
+	// The same origins are allowed for REST calls and WebSocket upgrades
+	originPolicy, err := auth.OriginPolicyFromEnv()
+	if err != nil {
+		panic(fmt.Sprintf("Invalid ALLOWED_ORIGINS: %v", err))
+	}
+	corsConfig := cors.Config{
+		AllowMethods: []string{"PUT", "PATCH", "POST", "GET", "DELETE", "OPTIONS"},
 		AllowHeaders: []string{
 			"Authorization",
 			"Content-Type",
 			"Access-Control-Allow-Origin",
 			"Access-Control-Allow-Credentials",
+			"X-Correlation-ID",
 		},
-	}))
+		ExposeHeaders: []string{"X-Next-Cursor"},
+	}
+	// cors refuses a config that sets both
+	if originPolicy.AllowAny() {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOrigins = originPolicy.Origins()
+	}
+	router.Use(cors.New(corsConfig))
 
 	router.Handle("GET", "/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -65,11 +93,112 @@ func main() {
 		})
 	})
 
-	go chat.HandleMessages(pool)
-	go tool.HandleMessages(pool)
+	toolRouterSyncInterval, err := time.ParseDuration(os.Getenv("TOOL_ROUTER_SYNC_INTERVAL"))
+	if err != nil || toolRouterSyncInterval <= 0 {
+		toolRouterSyncInterval = 5 * time.Minute
+	}
+	go tool.RunToolRouterSync(ctx, tool.NewToolService(ctx, pool), toolRouterSyncInterval)
+
+	// Deleted sessions stay in the trash for the retention window before they are purged
+	sessionRetention, err := time.ParseDuration(os.Getenv("SESSION_RETENTION"))
+	if err != nil || sessionRetention <= 0 {
+		sessionRetention = 30 * 24 * time.Hour
+	}
+	sessionPurgeInterval, err := time.ParseDuration(os.Getenv("SESSION_PURGE_INTERVAL"))
+	if err != nil || sessionPurgeInterval <= 0 {
+		sessionPurgeInterval = time.Hour
+	}
+	go session.RunSessionPurge(ctx, session.NewSessionService(ctx, pool), sessionRetention, sessionPurgeInterval)
+
+	hubConfig := hub.ConfigFromEnv()
+	chatHub := hub.NewHub(hubConfig)
+	toolHub := hub.NewHub(hubConfig)
+
+	// Fan hub messages out to the other server instances
+	relay := hub.NewRelay(hub.NewPostgresBroker(pool))
+	relay.Register("chat", chatHub)
+	relay.Register("tool", toolHub)
+	go relay.Run(ctx)
+
+	// Titles and summaries are refreshed every SESSION_SUMMARY_EVERY chat messages and announced to chat clients
+	sessionSummaryEvery, err := strconv.Atoi(os.Getenv("SESSION_SUMMARY_EVERY"))
+	if err != nil || sessionSummaryEvery < 1 {
+		sessionSummaryEvery = 10
+	}
+	sessionSummaryInterval, err := time.ParseDuration(os.Getenv("SESSION_SUMMARY_INTERVAL"))
+	if err != nil || sessionSummaryInterval <= 0 {
+		sessionSummaryInterval = time.Minute
+	}
+	go summary.RunSessionSummaries(ctx, summary.NewSummaryService(ctx, pool), chatHub, sessionSummaryEvery, sessionSummaryInterval)
+
+	// Presence goes to the chat hub, which is where clients of a session are listed
+	presenceTracker := presence.NewTracker(pool, chatHub)
+	presenceHeartbeatInterval, err := time.ParseDuration(os.Getenv("PRESENCE_HEARTBEAT_INTERVAL"))
+	if err != nil || presenceHeartbeatInterval <= 0 {
+		presenceHeartbeatInterval = 30 * time.Second
+	}
+	go presenceTracker.Run(ctx, presenceHeartbeatInterval)
+
+	router.GET("/metrics/ws", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"chat": chatHub.Stats(),
+			"tool": toolHub.Stats(),
+		})
+	})
+
+	ticketIssuer := auth.TicketIssuerFromEnv()
+	socketGuard := auth.NewSocketGuard(originPolicy, ticketIssuer)
 
-	app.SetupRoutes(ctx, router, pool)
+	operations := inflight.NewTracker(pool)
+	// Tool assignments are announced to the chat clients, like presence
+	assignments := assignment.NewWorkflow(pool, chatHub)
 
-	//
-	router.Run(fmt.Sprintf(":%s", port))
+	app.SetupRoutes(ctx, router, pool, chatHub, toolHub, presenceTracker, ticketIssuer, socketGuard, operations, assignments)
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", port),
+		Handler: router,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Println("Server Error:", err)
+		return
+	case <-ctx.Done():
+	}
+	stop()
+	log.Println("Shutting down")
+
+	shutdownTimeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil || shutdownTimeout <= 0 {
+		shutdownTimeout = 30 * time.Second
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections. Shutdown then waits for active requests, which includes event streams
+	// until the hubs close them below; hijacked WebSocket connections are left to the hubs.
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- server.Shutdown(shutdownCtx)
+	}()
+
+	// Clients reconnect, to another instance once this one is gone, and resume where they left off
+	for _, h := range []*hub.Hub{chatHub, toolHub} {
+		if err := h.Shutdown(shutdownCtx, websocket.CloseServiceRestart, hub.CloseReasonRestarting); err != nil {
+			log.Println("Hub Shutdown Error:", err)
+		}
+	}
+
+	// AI answers and tool runs that don't finish in time are recorded as interrupted
+	operations.Drain(shutdownCtx)
+
+	if err := <-shutdownErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Println("Server Shutdown Error:", err)
+	}
+	// The pool is closed last by the deferred pool.Close
 }
@@ -15,3 +15,19 @@ type SelectedTool struct {
 	ToolName string    `json:"tool_name"`
 	ToolID   uuid.UUID `json:"tool_id"`
 }
+
+type RegisteredTool struct {
+	ToolID      uuid.UUID `json:"tool_id"`
+	ToolName    string    `json:"tool_name"`
+	Version     string    `json:"version"`
+	Description string    `json:"description"`
+}
+
+type RankToolsRequestDTO struct {
+	UserPrompt string `json:"user_prompt"`
+	TopK       int    `json:"top_k"`
+}
+
+type RankToolsResponseDTO struct {
+	Tools []SelectToolResponseDTO `json:"tools"`
+}
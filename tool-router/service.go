@@ -7,34 +7,55 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type ToolRouterService interface {
-	SelectTool(prompt string) (*SelectedTool, error)
+	SelectTool(rctx context.Context, prompt string) (*SelectedTool, error)
+	RegisterTool(rctx context.Context, tool *RegisteredTool) error
+	UnregisterTool(rctx context.Context, toolID uuid.UUID) error
+	ListTools(rctx context.Context) ([]*RegisteredTool, error)
 }
 
+// ToolRanker is implemented by routers that can return several candidate tools ordered by relevance.
+type ToolRanker interface {
+	RankTools(rctx context.Context, prompt string, k int) ([]*SelectedTool, error)
+}
+
+// requestTimeout bounds every call to the router so a hung router cannot stall a request or the sync loop.
+const requestTimeout = 10 * time.Second
+
 type toolRouterService struct {
-	ctx  context.Context
-	host string
+	ctx    context.Context
+	host   string
+	client *http.Client
 }
 
 func NewToolRouterService(c context.Context) ToolRouterService {
-	return &toolRouterService{ctx: c, host: os.Getenv("TOOL_ROUTER_HOST")}
+	return &toolRouterService{
+		ctx:    c,
+		host:   os.Getenv("TOOL_ROUTER_HOST"),
+		client: &http.Client{Timeout: requestTimeout},
+	}
 }
 
-func (trs *toolRouterService) SelectTool(prompt string) (*SelectedTool, error) {
-	req := SelectToolRequestDTO{
+func (trs *toolRouterService) SelectTool(rctx context.Context, prompt string) (*SelectedTool, error) {
+	reqBody, err := json.Marshal(SelectToolRequestDTO{
 		UserPrompt: prompt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %s", err)
 	}
 
-	reqBody, err := json.Marshal(req)
+	req, err := http.NewRequestWithContext(rctx, http.MethodPost, trs.host+"/select", bytes.NewBuffer(reqBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request body: %s", err)
+		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
 
-	res, err := http.Post(trs.host+"/select", "application/json", bytes.NewBuffer(reqBody))
+	res, err := trs.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -59,3 +80,114 @@ func (trs *toolRouterService) SelectTool(prompt string) (*SelectedTool, error) {
 		ToolID:   toolID,
 	}, nil
 }
+
+func (trs *toolRouterService) RankTools(rctx context.Context, prompt string, k int) ([]*SelectedTool, error) {
+	reqBody, err := json.Marshal(RankToolsRequestDTO{
+		UserPrompt: prompt,
+		TopK:       k,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %s", err)
+	}
+
+	req, err := http.NewRequestWithContext(rctx, http.MethodPost, trs.host+"/rank", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := trs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to rank tools: %s", res.Status)
+	}
+
+	var response RankToolsResponseDTO
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	tools := make([]*SelectedTool, 0, len(response.Tools))
+	for _, tool := range response.Tools {
+		toolID, err := uuid.Parse(tool.SelectedToolID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tool ID format: %s", err)
+		}
+		tools = append(tools, &SelectedTool{ToolName: tool.SelectedToolName, ToolID: toolID})
+	}
+	return tools, nil
+}
+
+// RegisterTool creates or replaces the tool in the router catalog.
+func (trs *toolRouterService) RegisterTool(rctx context.Context, tool *RegisteredTool) error {
+	reqBody, err := json.Marshal(tool)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %s", err)
+	}
+
+	req, err := http.NewRequestWithContext(rctx, http.MethodPut, trs.host+"/tools/"+tool.ToolID.String(), bytes.NewBuffer(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := trs.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("failed to register tool: %s", res.Status)
+	}
+	return nil
+}
+
+func (trs *toolRouterService) UnregisterTool(rctx context.Context, toolID uuid.UUID) error {
+	req, err := http.NewRequestWithContext(rctx, http.MethodDelete, trs.host+"/tools/"+toolID.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := trs.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// The tool is already gone from the router, which is what we wanted.
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("failed to unregister tool: %s", res.Status)
+	}
+	return nil
+}
+
+func (trs *toolRouterService) ListTools(rctx context.Context) ([]*RegisteredTool, error) {
+	req, err := http.NewRequestWithContext(rctx, http.MethodGet, trs.host+"/tools", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := trs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list tools: %s", res.Status)
+	}
+
+	var tools []*RegisteredTool
+	if err := json.NewDecoder(res.Body).Decode(&tools); err != nil {
+		return nil, err
+	}
+	return tools, nil
+}